@@ -78,6 +78,26 @@ func (r *RedisCache) Set(key string, value interface{}, expiration time.Duration
 	return nil
 }
 
+// SetNX stores a key-value pair only if the key does not exist yet.
+// Returns true if the value was stored, false if the key was already present.
+func (r *RedisCache) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
+	defer cancel()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("failed to marshal cache value: %v", err)
+		return false, err
+	}
+
+	ok, err := r.client.SetNX(ctx, key, data, expiration).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set cache for key %s: %w", key, err)
+	}
+
+	return ok, nil
+}
+
 // Get retrieves the value associated with a given key from the cache.
 func (r *RedisCache) Get(key string, value interface{}) error {
 	ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
@@ -109,6 +129,11 @@ func (r *RedisCache) Delete(key string) error {
 	return nil
 }
 
+// Client returns the underlying Redis client, so that other components can share the connection.
+func (r *RedisCache) Client() *redis.Client {
+	return r.client
+}
+
 // Close gracefully closes the Redis client connection.
 func (r *RedisCache) Close() error {
 	if err := r.client.Close(); err != nil {
@@ -0,0 +1,17 @@
+// Command kafka-replay re-feeds the messages of a topic within a time window or an offset range through the
+// built-in "log" handler or into another topic. The brokers are configured by the KAFKA_* config keys, from
+// ./config/config.env when ENV is local or from the environment otherwise.
+//
+// Services replaying through their own handlers build the same command with replay.Register and replay.Main.
+//
+// Usage:
+//
+//	kafka-replay -topic dbserver.public.users -from 2024-05-01T00:00:00Z -to 2024-05-02T00:00:00Z \
+//	    -tables users -to-topic dbserver.public.users.replay -dry-run
+package main
+
+import "github.com/ngdangkietswe/swe-go-common-shared/kafka/replay"
+
+func main() {
+	replay.Main()
+}
@@ -3,4 +3,9 @@ package constants
 const (
 	UserPermissionCacheKeyPrefix = "user_permission"
 	ResetPasswordCacheKeyPrefix  = "reset_password"
+	KafkaDedupCacheKeyPrefix     = "kafka_dedup"
+	SagaCacheKeyPrefix           = "saga"
+	KafkaSchedulerCacheKeyPrefix = "kafka_scheduler"
+	TokenFamilyCacheKeyPrefix    = "token_family"
+	TokenDenylistCacheKey        = "token_denylist"
 )
@@ -1,5 +1,8 @@
 package constants
 
 const (
-	GrpcMetadataUserPermission = "grpc-user-permission"
+	GrpcMetadataUserPermission          = "grpc-user-permission"
+	GrpcMetadataUserPermissionSignature = "grpc-user-permission-signature"
+	GrpcMetadataRequestId               = "x-request-id"
+	GrpcMetadataCorrelationId           = "x-correlation-id"
 )
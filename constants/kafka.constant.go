@@ -0,0 +1,7 @@
+package constants
+
+const (
+	KafkaHeaderEventId       = "event-id"
+	KafkaHeaderTraceParent   = "traceparent"
+	KafkaHeaderCorrelationId = "x-correlation-id"
+)
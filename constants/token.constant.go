@@ -0,0 +1,19 @@
+package constants
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// TokenUseService is the token_use claim of service tokens. Other tokens, even with a client_id, belong to a user.
+const TokenUseService = "service"
+
+const (
+	ClaimClientId           = "client_id"
+	ClaimTokenUse           = "token_use"
+	ClaimRoles              = "roles"
+	ClaimScope              = "scope"
+	ClaimPermissionsVersion = "pv"
+	ClaimPermissionsHash    = "ph"
+	ClaimFamilyId           = "fid"
+)
@@ -1,5 +1,6 @@
 package constant
 
 const (
+	// Deprecated: string context keys collide across packages, use util.WithPrincipal and util.PrincipalFrom.
 	CtxPrincipalKey = "principal"
 )
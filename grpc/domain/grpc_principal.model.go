@@ -1,12 +1,54 @@
 package domain
 
-import "github.com/ngdangkietswe/swe-go-common-shared/domain"
+import (
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"strings"
+)
+
+// PrincipalKind tells whether a principal is a user or a service identity.
+type PrincipalKind string
+
+const (
+	PrincipalKindUser    PrincipalKind = "user"
+	PrincipalKindService PrincipalKind = "service"
+)
 
 type SweGrpcPrincipal struct {
+	Kind           PrincipalKind          `json:"kind"`
+	ClientId       string                 `json:"client_id"`
 	UserId         string                 `json:"user_id"`
 	Username       string                 `json:"username"`
 	Email          string                 `json:"email"`
 	Token          string                 `json:"token"`
 	Roles          []string               `json:"roles"`
 	UserPermission *domain.UserPermission `json:"permissions"`
+
+	// Scopes, PermissionsVersion and PermissionsHash come from the scope, pv and ph claims of the token.
+	Scopes             []string `json:"scopes"`
+	PermissionsVersion string   `json:"permissions_version"`
+	PermissionsHash    string   `json:"permissions_hash"`
+}
+
+// IsService returns true if the principal is a service identity, authorized by its scopes.
+func (p *SweGrpcPrincipal) IsService() bool {
+	return p != nil && p.Kind == PrincipalKindService
+}
+
+// HasRole returns true if the principal has the role, ignoring case.
+func (p *SweGrpcPrincipal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, r := range p.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSelf returns true if the principal is the given user, e.g. to let users access their own resources.
+func (p *SweGrpcPrincipal) IsSelf(userId string) bool {
+	return p != nil && !p.IsService() && userId != "" && p.UserId == userId
 }
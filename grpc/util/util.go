@@ -5,28 +5,106 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
 	"github.com/ngdangkietswe/swe-go-common-shared/grpc/constant"
 	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
 )
 
 // AsGrpcPrincipal is a function that converts a jwt.MapClaims to a SweGrpcPrincipal
+// Service tokens, with the token_use claim set by GenerateServiceToken, convert to a service principal.
 func AsGrpcPrincipal(claims *jwt.MapClaims) (*domain.SweGrpcPrincipal, error) {
-	claimsUser := (*claims)["user"].(map[string]interface{})
-	bytes, err := json.Marshal(&claimsUser)
+	principal := &domain.SweGrpcPrincipal{}
+
+	if stringClaim((*claims)[constants.ClaimTokenUse]) == constants.TokenUseService {
+		clientId := stringClaim((*claims)[constants.ClaimClientId])
+		if clientId == "" {
+			return nil, fmt.Errorf("invalid principal in context")
+		}
+
+		principal.Kind = domain.PrincipalKindService
+		principal.ClientId = clientId
+		principal.Username = clientId
+	} else {
+		claimsUser, ok := (*claims)["user"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid principal in context")
+		}
+
+		bytes, err := json.Marshal(&claimsUser)
 
-	if err != nil {
-		return nil, fmt.Errorf("invalid principal in context")
+		if err != nil {
+			return nil, fmt.Errorf("invalid principal in context")
+		}
+
+		if err = json.Unmarshal(bytes, principal); err != nil {
+			return nil, err
+		}
+		principal.Kind = domain.PrincipalKindUser
 	}
 
-	principal := &domain.SweGrpcPrincipal{}
-	err = json.Unmarshal(bytes, principal)
-	return principal, err
+	principal.Roles = stringsClaim((*claims)[constants.ClaimRoles])
+	principal.Scopes = strings.Fields(stringClaim((*claims)[constants.ClaimScope]))
+	principal.PermissionsVersion = stringClaim((*claims)[constants.ClaimPermissionsVersion])
+	principal.PermissionsHash = stringClaim((*claims)[constants.ClaimPermissionsHash])
+
+	return principal, nil
 }
 
-// GetGrpcPrincipal is a function that gets the SweGrpcPrincipal from the context
-func GetGrpcPrincipal(ctx context.Context) *domain.SweGrpcPrincipal {
-	if principal, ok := ctx.Value(constant.CtxPrincipalKey).(*domain.SweGrpcPrincipal); ok {
-		return principal
+// stringClaim returns a string claim, or empty if missing.
+func stringClaim(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+// stringsClaim returns a string list claim, or nil if missing.
+func stringsClaim(value interface{}) []string {
+	values, _ := value.([]interface{})
+
+	var result []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// principalCtxKey is the context key of the principal, unexported so that no other package can collide with it.
+type principalCtxKey struct{}
+
+// WithPrincipal is a function that returns a copy of the context carrying the principal
+func WithPrincipal(ctx context.Context, principal *domain.SweGrpcPrincipal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, principal)
+}
+
+// PrincipalFrom is a function that gets the SweGrpcPrincipal from the context, if any
+func PrincipalFrom(ctx context.Context) (*domain.SweGrpcPrincipal, bool) {
+	if principal, ok := ctx.Value(principalCtxKey{}).(*domain.SweGrpcPrincipal); ok && principal != nil {
+		return principal, true
 	}
-	return nil
+
+	// Principals stored under the deprecated string key.
+	if principal, ok := ctx.Value(constant.CtxPrincipalKey).(*domain.SweGrpcPrincipal); ok && principal != nil {
+		return principal, true
+	}
+
+	return nil, false
+}
+
+// MustPrincipal is a function that gets the SweGrpcPrincipal from the context, or an Unauthenticated error for anonymous calls
+func MustPrincipal(ctx context.Context) (*domain.SweGrpcPrincipal, error) {
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthenticated")
+	}
+	return principal, nil
+}
+
+// GetGrpcPrincipal is a function that gets the SweGrpcPrincipal from the context, or nil for anonymous calls
+func GetGrpcPrincipal(ctx context.Context) *domain.SweGrpcPrincipal {
+	principal, _ := PrincipalFrom(ctx)
+	return principal
 }
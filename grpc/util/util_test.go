@@ -0,0 +1,69 @@
+package util
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// TestPrincipalFrom is a function to test PrincipalFrom and MustPrincipal functions.
+func TestPrincipalFrom(t *testing.T) {
+	if _, ok := PrincipalFrom(context.Background()); ok {
+		t.Errorf("PrincipalFrom failed: expected no principal")
+	}
+	if _, err := MustPrincipal(context.Background()); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("MustPrincipal failed: expected %v but got %v", codes.Unauthenticated, err)
+	}
+
+	// A string key of another package does not collide with the principal.
+	ctx := context.WithValue(context.Background(), "principal", "value")
+	ctx = WithPrincipal(ctx, &domain.SweGrpcPrincipal{UserId: "user-1", Roles: []string{"Admin"}})
+
+	principal, err := MustPrincipal(ctx)
+	if err != nil || principal.UserId != "user-1" {
+		t.Fatalf("MustPrincipal failed: expected user-1 but got %v (err=%v)", principal, err)
+	}
+	if !principal.HasRole("admin") || principal.HasRole("guest") {
+		t.Errorf("HasRole failed: unexpected result for roles %v", principal.Roles)
+	}
+	if !principal.IsSelf("user-1") || principal.IsSelf("user-2") || principal.IsSelf("") {
+		t.Errorf("IsSelf failed: unexpected result for user %s", principal.UserId)
+	}
+}
+
+// TestAsGrpcPrincipal is a function to test the principal kind of AsGrpcPrincipal function.
+func TestAsGrpcPrincipal(t *testing.T) {
+	user := map[string]interface{}{"user_id": "user-1", "username": "john"}
+
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		kind     domain.PrincipalKind
+		clientId string
+		hasError bool
+	}{
+		{"user", jwt.MapClaims{"user": user}, domain.PrincipalKindUser, "", false},
+		// Access tokens of standard issuers carry the client the user signed in with (RFC 9068).
+		{"user with client_id", jwt.MapClaims{"user": user, "client_id": "web-app", "scope": "user:read"}, domain.PrincipalKindUser, "", false},
+		{"service", jwt.MapClaims{"client_id": "billing-job", "token_use": "service"}, domain.PrincipalKindService, "billing-job", false},
+		{"service without client_id", jwt.MapClaims{"token_use": "service"}, "", "", true},
+		{"client_id without user", jwt.MapClaims{"client_id": "billing-job"}, "", "", true},
+	}
+
+	for _, test := range tests {
+		principal, err := AsGrpcPrincipal(&test.claims)
+		if (err != nil) != test.hasError {
+			t.Errorf("AsGrpcPrincipal failed for %s: expected error %v but got %v", test.name, test.hasError, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if principal.Kind != test.kind || principal.ClientId != test.clientId {
+			t.Errorf("AsGrpcPrincipal failed for %s: expected %s %q but got %s %q", test.name, test.kind, test.clientId, principal.Kind, principal.ClientId)
+		}
+	}
+}
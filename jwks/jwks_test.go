@@ -0,0 +1,191 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func mustGenerateKey(t *testing.T, id, algorithm string) *Key {
+	t.Helper()
+	key, err := GenerateKey(id, algorithm)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	return key
+}
+
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+// TestKeySetRotate is a function to test signing and verification across a key rotation.
+func TestKeySetRotate(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		keySet := NewKeySet(mustGenerateKey(t, "k1", algorithm))
+
+		old, err := keySet.Sign(claims())
+		if err != nil {
+			t.Fatalf("Sign failed for %s: %v", algorithm, err)
+		}
+
+		keySet.Rotate(mustGenerateKey(t, "k2", algorithm))
+		current, err := keySet.Sign(claims())
+		if err != nil {
+			t.Fatalf("Sign failed for %s: %v", algorithm, err)
+		}
+
+		for name, token := range map[string]string{"retired": old, "active": current} {
+			if _, err = jwt.Parse(token, keySet.Keyfunc); err != nil {
+				t.Errorf("Keyfunc failed for %s %s key: %v", algorithm, name, err)
+			}
+		}
+
+		_ = keySet.Remove("k1")
+		if _, err = jwt.Parse(old, keySet.Keyfunc); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Remove failed for %s: expected %v but got %v", algorithm, ErrUnknownKey, err)
+		}
+		if err = keySet.Remove("k2"); err == nil {
+			t.Errorf("Remove failed for %s: expected an error removing the active key", algorithm)
+		}
+	}
+}
+
+// TestKeyfuncAlgorithmMismatch is a function to test that a token cannot claim another algorithm than its key.
+func TestKeyfuncAlgorithmMismatch(t *testing.T) {
+	keySet := NewKeySet(mustGenerateKey(t, "k1", AlgorithmRS256))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	token.Header["kid"] = "k1"
+	signed, _ := token.SignedString([]byte("secret"))
+
+	if _, err := jwt.Parse(signed, keySet.Keyfunc); err == nil {
+		t.Errorf("Keyfunc failed: expected an error for a HS256 token")
+	}
+}
+
+// TestHTTPLoader is a function to test loading and caching a JWKS from an HTTP endpoint.
+func TestHTTPLoader(t *testing.T) {
+	issuer := NewKeySet(mustGenerateKey(t, "k1", AlgorithmES256))
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		issuer.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	loader := NewHTTPLoader(server.URL, WithTTL(time.Hour), WithMinRefreshInterval(0))
+
+	token, _ := issuer.Sign(claims())
+	for i := 0; i < 3; i++ {
+		if _, err := jwt.Parse(token, loader.Keyfunc); err != nil {
+			t.Fatalf("Keyfunc failed: %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Keyfunc failed: expected %d request but got %d", 1, requests.Load())
+	}
+
+	// A token of a rotated key reloads the JWKS before the TTL expires.
+	issuer.Rotate(mustGenerateKey(t, "k2", AlgorithmEdDSA))
+	token, _ = issuer.Sign(claims())
+	if _, err := jwt.Parse(token, loader.Keyfunc); err != nil {
+		t.Errorf("Keyfunc failed after rotation: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Keyfunc failed: expected %d requests but got %d", 2, requests.Load())
+	}
+}
+
+// TestHTTPLoaderOutage is a function to test the loader while the JWKS endpoint fails or hangs.
+func TestHTTPLoaderOutage(t *testing.T) {
+	issuer := NewKeySet(mustGenerateKey(t, "k1", AlgorithmES256))
+
+	var requests atomic.Int32
+	var failing, hanging atomic.Bool
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if hanging.Load() {
+			<-release
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		issuer.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// Without keys, a failed fetch is not retried before the minimum refresh interval.
+	failing.Store(true)
+	loader := NewHTTPLoader(server.URL, WithTTL(time.Millisecond), WithMinRefreshInterval(time.Hour))
+	for i := 0; i < 3; i++ {
+		if _, err := loader.KeySet(context.Background()); err == nil {
+			t.Fatalf("KeySet failed: expected error during outage")
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("KeySet failed: expected %d request but got %d", 1, requests.Load())
+	}
+
+	// A stale key set is served without waiting for a hanging reload.
+	failing.Store(false)
+	loader = NewHTTPLoader(server.URL, WithTTL(time.Millisecond), WithMinRefreshInterval(0))
+	if _, err := loader.KeySet(context.Background()); err != nil {
+		t.Fatalf("KeySet failed: %v", err)
+	}
+
+	hanging.Store(true)
+	time.Sleep(2 * time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if keySet, err := loader.KeySet(context.Background()); err != nil || keySet == nil {
+			t.Fatalf("KeySet failed: expected stale key set but got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("KeySet failed: expected stale key set without waiting but took %s", elapsed)
+	}
+	for deadline := time.Now().Add(time.Second); requests.Load() < 3 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("KeySet failed: expected a single shared reload, %d requests but got %d", 3, requests.Load())
+	}
+}
+
+// TestFileLoader is a function to test loading a JWKS from a file.
+func TestFileLoader(t *testing.T) {
+	issuer := NewKeySet(mustGenerateKey(t, "k1", AlgorithmRS256))
+
+	data, _ := json.Marshal(issuer.JWKS())
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	keySet, err := NewFileLoader(path).KeySet(context.Background())
+	if err != nil {
+		t.Fatalf("KeySet failed: %v", err)
+	}
+
+	key, ok := keySet.Key("k1")
+	if !ok || key.PrivateKey != nil || key.Algorithm != AlgorithmRS256 {
+		t.Errorf("KeySet failed: unexpected key %+v", key)
+	}
+	if _, err = keySet.Sign(claims()); err == nil {
+		t.Errorf("Sign failed: expected an error signing with a verification-only key set")
+	}
+}
@@ -0,0 +1,139 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is an asymmetric signing key identified by its kid. Keys loaded from a JWKS have no private key
+// and can only verify.
+type Key struct {
+	Id         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+// NewKey creates a key from a private key, inferring the algorithm from its type.
+func NewKey(id string, privateKey crypto.Signer) (*Key, error) {
+	algorithm, err := algorithmOf(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{Id: id, Algorithm: algorithm, PrivateKey: privateKey, PublicKey: privateKey.Public()}, nil
+}
+
+// GenerateKey generates a new key for the algorithm: a 2048 bits RSA key, a P-256 ECDSA key or an Ed25519 key.
+func GenerateKey(id, algorithm string) (*Key, error) {
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch algorithm {
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", algorithm)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+	return NewKey(id, privateKey)
+}
+
+// ParsePrivateKeyPEM parses a PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) PEM encoded private key.
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM private key")
+	}
+
+	var (
+		privateKey any
+		err        error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+	return NewKey(id, signer)
+}
+
+// LoadKeyFile reads a PEM encoded private key file.
+func LoadKeyFile(id, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+	return ParsePrivateKeyPEM(id, data)
+}
+
+// SigningMethod returns the jwt signing method of the key.
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// Sign signs the claims with the key, setting the kid header.
+func (k *Key) Sign(claims jwt.Claims) (string, error) {
+	if k.PrivateKey == nil {
+		return "", fmt.Errorf("key %s cannot sign without a private key", k.Id)
+	}
+
+	token := jwt.NewWithClaims(k.SigningMethod(), claims)
+	token.Header["kid"] = k.Id
+	return token.SignedString(k.PrivateKey)
+}
+
+// algorithmOf returns the signing algorithm of a public key.
+func algorithmOf(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRS256, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported elliptic curve %s", key.Curve.Params().Name)
+		}
+		return AlgorithmES256, nil
+	case ed25519.PublicKey:
+		return AlgorithmEdDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
@@ -0,0 +1,125 @@
+package jwks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownKey is returned when a token is signed by a kid missing from the key set.
+var ErrUnknownKey = errors.New("jwks: unknown key")
+
+// KeySet holds the active signing key and the retired keys still valid for verification.
+type KeySet struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+}
+
+// NewKeySet creates a key set signing with the active key, nil for a verification-only set.
+func NewKeySet(active *Key, retired ...*Key) *KeySet {
+	keySet := &KeySet{keys: make(map[string]*Key)}
+	for _, key := range retired {
+		keySet.keys[key.Id] = key
+	}
+
+	if active != nil {
+		keySet.active = active
+		keySet.keys[active.Id] = active
+	}
+
+	return keySet
+}
+
+// Active returns the signing key.
+func (s *KeySet) Active() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// Key returns the key of the kid.
+func (s *KeySet) Key(id string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[id]
+	return key, ok
+}
+
+// Add adds a verification key.
+func (s *KeySet) Add(key *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.Id] = key
+}
+
+// Rotate makes the key the signing key. The previous one is retired and still verifies the tokens it signed.
+func (s *KeySet) Rotate(key *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = key
+	s.keys[key.Id] = key
+}
+
+// Remove drops a retired key, invalidating the tokens it signed. The active key cannot be removed.
+func (s *KeySet) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active != nil && s.active.Id == id {
+		return fmt.Errorf("cannot remove the active key %s", id)
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+// Sign signs the claims with the active key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	active := s.Active()
+	if active == nil {
+		return "", fmt.Errorf("no active signing key")
+	}
+	return active.Sign(claims)
+}
+
+// Keyfunc is a jwt.Keyfunc resolving the verification key from the kid header. The token algorithm
+// must match the key, which rules out algorithm confusion.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := s.Key(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), id)
+	}
+	return key.PublicKey, nil
+}
+
+// JWKS returns the public keys to publish on a JWKS endpoint, sorted by kid.
+func (s *KeySet) JWKS() JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+// ServeHTTP serves the public keys as a JWKS endpoint.
+func (s *KeySet) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_ = json.NewEncoder(w).Encode(s.JWKS())
+}
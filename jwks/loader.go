@@ -0,0 +1,242 @@
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// fetchTimeout bounds a JWKS fetch, shared by every caller waiting for it.
+const fetchTimeout = 10 * time.Second
+
+// Loader loads a JWKS from a file or an HTTP endpoint and caches it. Fetches run outside the lock and are shared
+// by concurrent callers; after a failed fetch, the loader backs off for the minimum refresh interval.
+type Loader struct {
+	fetch      func(ctx context.Context) ([]byte, error)
+	ttl        time.Duration
+	minRefresh time.Duration
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	keySet      *KeySet
+	fetchedAt   time.Time
+	refreshedAt time.Time
+	failedAt    time.Time
+	lastErr     error
+	inflight    *loadCall
+}
+
+// loadCall is a fetch in progress, done once the key set is stored or the fetch failed.
+type loadCall struct {
+	done chan struct{}
+	err  error
+}
+
+// LoaderOption defines a function type for configuring the Loader.
+type LoaderOption func(*Loader)
+
+// WithTTL sets how long a loaded JWKS is cached.
+func WithTTL(ttl time.Duration) LoaderOption {
+	return func(l *Loader) {
+		l.ttl = ttl
+	}
+}
+
+// WithMinRefreshInterval limits how often an unknown kid forces a reload.
+func WithMinRefreshInterval(interval time.Duration) LoaderOption {
+	return func(l *Loader) {
+		l.minRefresh = interval
+	}
+}
+
+// WithHTTPClient sets the client fetching the JWKS endpoint.
+func WithHTTPClient(client *http.Client) LoaderOption {
+	return func(l *Loader) {
+		l.httpClient = client
+	}
+}
+
+// NewHTTPLoader creates a loader of the JWKS served at the URL.
+func NewHTTPLoader(url string, options ...LoaderOption) *Loader {
+	loader := newLoader(options...)
+	loader.fetch = func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := loader.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+		}
+		return io.ReadAll(resp.Body)
+	}
+	return loader
+}
+
+// NewFileLoader creates a loader of the JWKS stored in the file.
+func NewFileLoader(path string, options ...LoaderOption) *Loader {
+	loader := newLoader(options...)
+	loader.fetch = func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+	return loader
+}
+
+// NewLoader creates a loader from the JWKS_URL or, if empty, the JWKS_FILE config key.
+func NewLoader(options ...LoaderOption) *Loader {
+	if url := config.GetString("JWKS_URL", ""); url != "" {
+		return NewHTTPLoader(url, options...)
+	}
+	return NewFileLoader(config.GetString("JWKS_FILE", "jwks.json"), options...)
+}
+
+func newLoader(options ...LoaderOption) *Loader {
+	loader := &Loader{
+		ttl:        time.Duration(config.GetInt("JWKS_CACHE_TTL_SECONDS", 300)) * time.Second,
+		minRefresh: 30 * time.Second,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(loader)
+	}
+
+	return loader
+}
+
+// KeySet returns the cached key set, loading it when missing. A key set older than the TTL keeps being served
+// while it is reloaded in the background, and after a failed reload.
+func (l *Loader) KeySet(ctx context.Context) (*KeySet, error) {
+	l.mu.Lock()
+	if l.keySet != nil {
+		if time.Since(l.fetchedAt) >= l.ttl && time.Since(l.failedAt) >= l.minRefresh {
+			l.refresh()
+		}
+		keySet := l.keySet
+		l.mu.Unlock()
+		return keySet, nil
+	}
+
+	if l.lastErr != nil && time.Since(l.failedAt) < l.minRefresh {
+		err := l.lastErr
+		l.mu.Unlock()
+		return nil, err
+	}
+
+	call := l.refresh()
+	l.mu.Unlock()
+
+	if err := call.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.keySet, nil
+}
+
+// Keyfunc is a jwt.Keyfunc verifying tokens with the loaded keys. An unknown kid reloads the JWKS, at most
+// once per minimum refresh interval, so that keys rotated by the issuer are picked up before the TTL.
+func (l *Loader) Keyfunc(token *jwt.Token) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	keySet, err := l.KeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keySet.Keyfunc(token)
+	if !errors.Is(err, ErrUnknownKey) {
+		return key, err
+	}
+
+	l.mu.Lock()
+	var call *loadCall
+	if l.inflight != nil || time.Since(l.refreshedAt) >= l.minRefresh {
+		call = l.refresh()
+	}
+	l.mu.Unlock()
+
+	if call != nil {
+		if err = call.wait(ctx); err != nil {
+			log.Printf("Error while reloading JWKS: %v", err)
+		}
+	}
+
+	l.mu.Lock()
+	keySet = l.keySet
+	l.mu.Unlock()
+
+	return keySet.Keyfunc(token)
+}
+
+// refresh returns the fetch in progress, starting one if needed. It must be called with the lock held.
+func (l *Loader) refresh() *loadCall {
+	if l.inflight != nil {
+		return l.inflight
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	l.inflight = call
+	l.refreshedAt = time.Now()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+
+		keySet, err := l.load(ctx)
+
+		l.mu.Lock()
+		if err != nil {
+			l.failedAt, l.lastErr = time.Now(), err
+			if l.keySet != nil {
+				log.Printf("Error while reloading JWKS, using cached keys: %v", err)
+			}
+		} else {
+			l.keySet, l.fetchedAt, l.lastErr = keySet, time.Now(), nil
+		}
+		l.inflight = nil
+		l.mu.Unlock()
+
+		call.err = err
+		close(call.done)
+	}()
+
+	return call
+}
+
+// wait waits for the fetch to complete or the context to be done.
+func (c *loadCall) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// load fetches and parses the JWKS.
+func (l *Loader) load(ctx context.Context) (*KeySet, error) {
+	data, err := l.fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	return ParseJWKS(data)
+}
@@ -1,10 +1,12 @@
 package kafka
 
 type CdcEventMsg struct {
-	Source CdcSource              `json:"source"`
-	Op     CdcOperation           `json:"op"`
-	Before map[string]interface{} `json:"before"`
-	After  map[string]interface{} `json:"after"`
+	Source      CdcSource              `json:"source"`
+	Op          CdcOperation           `json:"op"`
+	Before      map[string]interface{} `json:"before"`
+	After       map[string]interface{} `json:"after"`
+	TsMs        int64                  `json:"ts_ms"`
+	Transaction *CdcTransaction        `json:"transaction,omitempty"`
 }
 
 type CdcSource struct {
@@ -13,13 +15,22 @@ type CdcSource struct {
 	Db        string `json:"db"`
 	Schema    string `json:"schema"`
 	Table     string `json:"table"`
+	TsMs      int64  `json:"ts_ms"`
+	Snapshot  string `json:"snapshot"`
+}
+
+type CdcTransaction struct {
+	Id                  string `json:"id"`
+	TotalOrder          int64  `json:"total_order"`
+	DataCollectionOrder int64  `json:"data_collection_order"`
 }
 
 type CdcOperation string
 
 const (
-	CdcOperationCreate CdcOperation = "c"
-	CdcOperationUpdate CdcOperation = "u"
-	CdcOperationRead   CdcOperation = "r"
-	CdcOperationDelete CdcOperation = "d"
+	CdcOperationCreate   CdcOperation = "c"
+	CdcOperationUpdate   CdcOperation = "u"
+	CdcOperationRead     CdcOperation = "r"
+	CdcOperationDelete   CdcOperation = "d"
+	CdcOperationTruncate CdcOperation = "t"
 )
@@ -0,0 +1,274 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	CleanupPolicyDelete  = "delete"
+	CleanupPolicyCompact = "compact"
+
+	configRetentionMs     = "retention.ms"
+	configCleanupPolicy   = "cleanup.policy"
+	useBrokerDefault      = -1
+	propertyPartitions    = "partitions"
+	propertyReplication   = "replication.factor"
+	describeConfigTimeout = 10 * time.Second
+)
+
+// TopicSpec declares a topic. Zero Partitions or ReplicationFactor use the broker defaults, and
+// a zero Retention or empty CleanupPolicy are left to the broker.
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Retention         time.Duration
+	CleanupPolicy     string
+	Configs           map[string]string
+}
+
+// Drift is a difference between the declared and the actual state of an existing topic.
+type Drift struct {
+	Topic    string `json:"topic"`
+	Property string `json:"property"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s expected %s but is %s", d.Topic, d.Property, d.Expected, d.Actual)
+}
+
+// Report is the outcome of EnsureTopics.
+type Report struct {
+	Created []string `json:"created"`
+	Drifts  []Drift  `json:"drifts"`
+}
+
+// HasDrift checks if any existing topic differs from its spec.
+func (r *Report) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+// topicState is the actual state of an existing topic.
+type topicState struct {
+	partitions        int
+	replicationFactor int
+	configs           map[string]string
+}
+
+// Admin provisions topics through the Kafka admin API.
+type Admin struct {
+	client *kafka.Client
+}
+
+// NewAdmin creates an admin client configured from the KAFKA_* config keys.
+// It panics if the configured SASL or TLS settings are invalid.
+func NewAdmin() *Admin {
+	clientConfig := swekafka.LoadClientConfig()
+
+	transport, err := clientConfig.Transport()
+	if err != nil {
+		panic(err)
+	}
+
+	return NewAdminWithClient(&kafka.Client{
+		Addr:      kafka.TCP(clientConfig.Brokers...),
+		Transport: transport,
+	})
+}
+
+// NewAdminWithClient creates an admin using the given kafka-go client.
+func NewAdminWithClient(client *kafka.Client) *Admin {
+	return &Admin{client: client}
+}
+
+// EnsureTopics creates the missing topics with a new admin client configured from the KAFKA_* config keys.
+func EnsureTopics(ctx context.Context, specs []TopicSpec) (*Report, error) {
+	return NewAdmin().EnsureTopics(ctx, specs)
+}
+
+// EnsureTopics creates the missing topics and reports the drift of the existing ones.
+// Existing topics are never altered.
+func (a *Admin) EnsureTopics(ctx context.Context, specs []TopicSpec) (*Report, error) {
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+
+	metadata, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe topics: %w", err)
+	}
+
+	existing := make(map[string]*topicState)
+	for _, topic := range metadata.Topics {
+		if topic.Error != nil || len(topic.Partitions) == 0 {
+			continue
+		}
+		existing[topic.Name] = &topicState{
+			partitions:        len(topic.Partitions),
+			replicationFactor: len(topic.Partitions[0].Replicas),
+		}
+	}
+
+	if err = a.describeConfigs(ctx, existing); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	var missing []kafka.TopicConfig
+	for _, spec := range specs {
+		if state, ok := existing[spec.Name]; ok {
+			report.Drifts = append(report.Drifts, diff(spec, state)...)
+			continue
+		}
+		missing = append(missing, spec.topicConfig())
+	}
+
+	if len(missing) > 0 {
+		resp, err := a.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: missing})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create topics: %w", err)
+		}
+
+		var errs []error
+		for _, topic := range missing {
+			if topicErr := resp.Errors[topic.Topic]; topicErr != nil && !errors.Is(topicErr, kafka.TopicAlreadyExists) {
+				errs = append(errs, fmt.Errorf("failed to create topic %s: %w", topic.Topic, topicErr))
+				continue
+			}
+			report.Created = append(report.Created, topic.Topic)
+		}
+
+		if len(errs) > 0 {
+			return report, errors.Join(errs...)
+		}
+	}
+
+	for _, drift := range report.Drifts {
+		log.Printf("Topic drift detected: %s", drift)
+	}
+
+	return report, nil
+}
+
+// describeConfigs loads the configs of the existing topics.
+func (a *Admin) describeConfigs(ctx context.Context, topics map[string]*topicState) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	resources := make([]kafka.DescribeConfigRequestResource, 0, len(topics))
+	for name := range topics {
+		resources = append(resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: name,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, describeConfigTimeout)
+	defer cancel()
+
+	resp, err := a.client.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: resources})
+	if err != nil {
+		return fmt.Errorf("failed to describe topic configs: %w", err)
+	}
+
+	for _, resource := range resp.Resources {
+		state, ok := topics[resource.ResourceName]
+		if !ok || resource.Error != nil {
+			continue
+		}
+
+		state.configs = make(map[string]string, len(resource.ConfigEntries))
+		for _, entry := range resource.ConfigEntries {
+			state.configs[entry.ConfigName] = entry.ConfigValue
+		}
+	}
+
+	return nil
+}
+
+// configs returns the topic configs declared by the spec.
+func (s TopicSpec) configs() map[string]string {
+	configs := make(map[string]string, len(s.Configs)+2)
+	for name, value := range s.Configs {
+		configs[name] = value
+	}
+
+	if s.Retention > 0 {
+		configs[configRetentionMs] = strconv.FormatInt(s.Retention.Milliseconds(), 10)
+	}
+
+	if s.CleanupPolicy != "" {
+		configs[configCleanupPolicy] = s.CleanupPolicy
+	}
+
+	return configs
+}
+
+// topicConfig converts the spec to a create topic request.
+func (s TopicSpec) topicConfig() kafka.TopicConfig {
+	topicConfig := kafka.TopicConfig{
+		Topic:             s.Name,
+		NumPartitions:     s.Partitions,
+		ReplicationFactor: s.ReplicationFactor,
+	}
+
+	if topicConfig.NumPartitions == 0 {
+		topicConfig.NumPartitions = useBrokerDefault
+	}
+
+	if topicConfig.ReplicationFactor == 0 {
+		topicConfig.ReplicationFactor = useBrokerDefault
+	}
+
+	for name, value := range s.configs() {
+		topicConfig.ConfigEntries = append(topicConfig.ConfigEntries, kafka.ConfigEntry{ConfigName: name, ConfigValue: value})
+	}
+
+	return topicConfig
+}
+
+// diff returns the differences between the spec and the actual state of the topic, sorted by property.
+func diff(spec TopicSpec, state *topicState) []Drift {
+	var drifts []Drift
+
+	if spec.Partitions > 0 && spec.Partitions != state.partitions {
+		drifts = append(drifts, Drift{
+			Topic:    spec.Name,
+			Property: propertyPartitions,
+			Expected: strconv.Itoa(spec.Partitions),
+			Actual:   strconv.Itoa(state.partitions),
+		})
+	}
+
+	if spec.ReplicationFactor > 0 && spec.ReplicationFactor != state.replicationFactor {
+		drifts = append(drifts, Drift{
+			Topic:    spec.Name,
+			Property: propertyReplication,
+			Expected: strconv.Itoa(spec.ReplicationFactor),
+			Actual:   strconv.Itoa(state.replicationFactor),
+		})
+	}
+
+	for name, expected := range spec.configs() {
+		if actual, ok := state.configs[name]; !ok || actual != expected {
+			drifts = append(drifts, Drift{Topic: spec.Name, Property: name, Expected: expected, Actual: actual})
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].Property < drifts[j].Property
+	})
+	return drifts
+}
@@ -0,0 +1,52 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDiff is a function to test diff function.
+func TestDiff(t *testing.T) {
+	spec := TopicSpec{
+		Name:              "auth.register_user.v1",
+		Partitions:        3,
+		ReplicationFactor: 3,
+		Retention:         24 * time.Hour,
+		CleanupPolicy:     CleanupPolicyDelete,
+	}
+
+	state := &topicState{
+		partitions:        3,
+		replicationFactor: 1,
+		configs:           map[string]string{"retention.ms": "86400000", "cleanup.policy": "compact"},
+	}
+
+	expected := []Drift{
+		{Topic: spec.Name, Property: "cleanup.policy", Expected: "delete", Actual: "compact"},
+		{Topic: spec.Name, Property: "replication.factor", Expected: "3", Actual: "1"},
+	}
+
+	if drifts := diff(spec, state); !reflect.DeepEqual(drifts, expected) {
+		t.Errorf("diff failed: expected %v but got %v", expected, drifts)
+	}
+
+	// Unset properties are left to the broker.
+	if drifts := diff(TopicSpec{Name: spec.Name}, state); len(drifts) != 0 {
+		t.Errorf("diff failed: expected no drift but got %v", drifts)
+	}
+}
+
+// TestTopicConfig is a function to test TopicSpec.topicConfig function.
+func TestTopicConfig(t *testing.T) {
+	topicConfig := AuthTopics[0].topicConfig()
+
+	if topicConfig.NumPartitions != 3 || topicConfig.ReplicationFactor != useBrokerDefault {
+		t.Errorf("topicConfig failed: unexpected partitions %d or replication %d",
+			topicConfig.NumPartitions, topicConfig.ReplicationFactor)
+	}
+
+	if len(topicConfig.ConfigEntries) != 2 {
+		t.Errorf("topicConfig failed: expected %d config entries but got %v", 2, topicConfig.ConfigEntries)
+	}
+}
@@ -0,0 +1,22 @@
+package admin
+
+import (
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"time"
+)
+
+// AuthTopics declares the topics published by the auth service.
+var AuthTopics = []TopicSpec{
+	{
+		Name:          constants.TopicRegisterUser,
+		Partitions:    3,
+		Retention:     7 * 24 * time.Hour,
+		CleanupPolicy: CleanupPolicyDelete,
+	},
+	{
+		Name:          constants.TopicResetPassword,
+		Partitions:    3,
+		Retention:     24 * time.Hour,
+		CleanupPolicy: CleanupPolicyDelete,
+	},
+}
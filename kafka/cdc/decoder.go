@@ -0,0 +1,72 @@
+package cdc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"reflect"
+	"sort"
+)
+
+// ErrTombstone is returned when the message is a Debezium tombstone (a null value following a delete).
+var ErrTombstone = errors.New("cdc: tombstone message")
+
+// Decode decodes a Debezium change event. Both the enveloped form ({"schema": ..., "payload": ...})
+// produced by the JSON converter with schemas enabled and the unwrapped form are supported.
+func Decode(data []byte) (*swekafka.CdcEventMsg, error) {
+	if isNull(data) {
+		return nil, ErrTombstone
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode cdc event: %w", err)
+	}
+
+	// With schemas enabled the change event is nested under "payload".
+	if payload, ok := fields["payload"]; ok {
+		if isNull(payload) {
+			return nil, ErrTombstone
+		}
+		data = payload
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	msg := &swekafka.CdcEventMsg{}
+	if err := decoder.Decode(msg); err != nil {
+		return nil, fmt.Errorf("failed to decode cdc payload: %w", err)
+	}
+
+	if msg.Op == "" {
+		return nil, fmt.Errorf("invalid cdc event: missing op")
+	}
+
+	return msg, nil
+}
+
+// ChangedFields returns the sorted names of the columns whose value differs between before and after.
+func ChangedFields(before, after map[string]interface{}) []string {
+	changed := make([]string, 0)
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// isNull checks if the given JSON document is empty or a literal null.
+func isNull(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
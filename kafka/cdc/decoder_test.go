@@ -0,0 +1,109 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"testing"
+)
+
+type User struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+const unwrappedUpdate = `{
+	"before": {"id": "1", "username": "john", "email": "john@example.com"},
+	"after": {"id": "1", "username": "john", "email": "john.doe@example.com"},
+	"source": {"connector": "postgresql", "db": "swe", "schema": "public", "table": "users"},
+	"op": "u",
+	"ts_ms": 1700000000000,
+	"transaction": {"id": "571", "total_order": 1, "data_collection_order": 1}
+}`
+
+// TestDecode is a function to test Decode function.
+func TestDecode(t *testing.T) {
+	msg, err := Decode([]byte(unwrappedUpdate))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if msg.Op != swekafka.CdcOperationUpdate || msg.Source.Table != "users" || msg.TsMs != 1700000000000 {
+		t.Errorf("Decode failed: unexpected event %+v", msg)
+	}
+
+	if msg.Transaction == nil || msg.Transaction.Id != "571" {
+		t.Errorf("Decode failed: expected transaction 571 but got %+v", msg.Transaction)
+	}
+
+	enveloped, err := Decode([]byte(`{"schema": {"type": "struct"}, "payload": ` + unwrappedUpdate + `}`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(msg, enveloped) {
+		t.Errorf("Decode failed: expected %+v but got %+v", msg, enveloped)
+	}
+}
+
+// TestDecodeTombstone is a function to test Decode function with tombstone messages.
+func TestDecodeTombstone(t *testing.T) {
+	for _, value := range []string{"", "null", `{"schema": null, "payload": null}`} {
+		if _, err := Decode([]byte(value)); !errors.Is(err, ErrTombstone) {
+			t.Errorf("Decode failed: expected ErrTombstone for %q but got %v", value, err)
+		}
+	}
+}
+
+// TestChangedFields is a function to test ChangedFields function.
+func TestChangedFields(t *testing.T) {
+	before := map[string]interface{}{"id": "1", "email": "a", "removed": true}
+	after := map[string]interface{}{"id": "1", "email": "b", "added": 1}
+
+	expected := []string{"added", "email", "removed"}
+	if changed := ChangedFields(before, after); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("ChangedFields failed: expected %v but got %v", expected, changed)
+	}
+}
+
+// TestHandlers is a function to test the dispatching of Handlers.
+func TestHandlers(t *testing.T) {
+	var updated *Event[User]
+	tombstones := 0
+
+	handlers := &Handlers[User]{
+		OnUpdate: func(_ context.Context, event *Event[User]) error {
+			updated = event
+			return nil
+		},
+		OnTombstone: func(_ context.Context, _ *Event[User]) error {
+			tombstones++
+			return nil
+		},
+	}
+
+	if err := handlers.Handle(context.Background(), kafka.Message{Value: []byte(unwrappedUpdate)}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	if updated == nil || updated.Before.Email != "john@example.com" || updated.After.Email != "john.doe@example.com" {
+		t.Fatalf("Handle failed: unexpected update event %+v", updated)
+	}
+
+	if !reflect.DeepEqual(updated.ChangedFields, []string{"email"}) {
+		t.Errorf("Handle failed: expected changed fields [email] but got %v", updated.ChangedFields)
+	}
+
+	if err := handlers.Handle(context.Background(), kafka.Message{Key: []byte("1")}); err != nil || tombstones != 1 {
+		t.Errorf("Handle failed: expected one tombstone but got %d (err=%v)", tombstones, err)
+	}
+
+	// Operations without a handler are skipped.
+	created := `{"after": {"id": "2"}, "source": {"table": "users"}, "op": "c"}`
+	if err := handlers.Handle(context.Background(), kafka.Message{Value: []byte(created)}); err != nil {
+		t.Errorf("Handle failed: %v", err)
+	}
+}
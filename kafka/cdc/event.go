@@ -0,0 +1,85 @@
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+// Event is a Debezium change event whose row images are mapped into T.
+type Event[T any] struct {
+	Key           []byte
+	Op            swekafka.CdcOperation
+	Source        swekafka.CdcSource
+	TsMs          int64
+	Transaction   *swekafka.CdcTransaction
+	Before        *T
+	After         *T
+	ChangedFields []string
+	Tombstone     bool
+	Raw           *swekafka.CdcEventMsg
+}
+
+// Time returns the time at which the connector processed the event.
+func (e *Event[T]) Time() time.Time {
+	return time.UnixMilli(e.TsMs)
+}
+
+// DecodeEvent decodes a Kafka message into a typed change event.
+// Tombstones are returned as an event with Tombstone set and no row images.
+func DecodeEvent[T any](msg kafka.Message) (*Event[T], error) {
+	raw, err := Decode(msg.Value)
+	if errors.Is(err, ErrTombstone) {
+		return &Event[T]{Key: msg.Key, Tombstone: true}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	before, err := asTyped[T](raw.Before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map cdc before image: %w", err)
+	}
+
+	after, err := asTyped[T](raw.After)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map cdc after image: %w", err)
+	}
+
+	event := &Event[T]{
+		Key:         msg.Key,
+		Op:          raw.Op,
+		Source:      raw.Source,
+		TsMs:        raw.TsMs,
+		Transaction: raw.Transaction,
+		Before:      before,
+		After:       after,
+		Raw:         raw,
+	}
+
+	if raw.Op == swekafka.CdcOperationUpdate {
+		event.ChangedFields = ChangedFields(raw.Before, raw.After)
+	}
+
+	return event, nil
+}
+
+// asTyped converts a row image into T. A nil image is returned as nil.
+func asTyped[T any](image map[string]interface{}) (*T, error) {
+	if image == nil {
+		return nil, nil
+	}
+
+	bytes, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+
+	value := new(T)
+	if err = json.Unmarshal(bytes, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
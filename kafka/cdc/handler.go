@@ -0,0 +1,74 @@
+package cdc
+
+import (
+	"context"
+	"fmt"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"log"
+)
+
+// HandlerFunc handles a typed change event.
+type HandlerFunc[T any] func(ctx context.Context, event *Event[T]) error
+
+// Handlers dispatches change events to the handler registered for their operation.
+// Operations without a registered handler are skipped.
+type Handlers[T any] struct {
+	OnCreate    HandlerFunc[T]
+	OnUpdate    HandlerFunc[T]
+	OnDelete    HandlerFunc[T]
+	OnSnapshot  HandlerFunc[T]
+	OnTruncate  HandlerFunc[T]
+	OnTombstone HandlerFunc[T]
+}
+
+// Handle decodes the Kafka message and dispatches it to the matching handler.
+func (h *Handlers[T]) Handle(ctx context.Context, msg kafka.Message) error {
+	event, err := DecodeEvent[T](msg)
+	if err != nil {
+		return err
+	}
+
+	handler, err := h.handlerFor(event)
+	if err != nil {
+		return err
+	}
+
+	if handler == nil {
+		return nil
+	}
+
+	return handler(ctx, event)
+}
+
+// Consumer adapts the handlers to the handler signature accepted by KConsumer.Consume.
+func (h *Handlers[T]) Consumer(ctx context.Context) func(msg kafka.Message) {
+	return func(msg kafka.Message) {
+		if err := h.Handle(ctx, msg); err != nil {
+			log.Printf("Error while handling cdc event: topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+		}
+	}
+}
+
+// handlerFor returns the handler registered for the operation of the event.
+func (h *Handlers[T]) handlerFor(event *Event[T]) (HandlerFunc[T], error) {
+	if event.Tombstone {
+		return h.OnTombstone, nil
+	}
+
+	switch event.Op {
+	case swekafka.CdcOperationCreate:
+		return h.OnCreate, nil
+	case swekafka.CdcOperationUpdate:
+		return h.OnUpdate, nil
+	case swekafka.CdcOperationDelete:
+		return h.OnDelete, nil
+	case swekafka.CdcOperationRead:
+		return h.OnSnapshot, nil
+	case swekafka.CdcOperationTruncate:
+		return h.OnTruncate, nil
+	default:
+		return nil, fmt.Errorf("unsupported cdc operation: %s", event.Op)
+	}
+}
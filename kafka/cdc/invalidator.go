@@ -0,0 +1,233 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyCacheRules is the config key holding the JSON encoded invalidation rules.
+	KeyCacheRules = "CDC_CACHE_RULES"
+
+	CacheActionInvalidate = "invalidate"
+	CacheActionRefresh    = "refresh"
+)
+
+// placeholderPattern matches the {column} placeholders of a key template.
+var placeholderPattern = regexp.MustCompile(`\{([^{}]+)}`)
+
+// CacheStore is the subset of cache.RedisCache used by the invalidator.
+type CacheStore interface {
+	Set(key string, value interface{}, expiration time.Duration) error
+	Delete(key string) error
+}
+
+// Refresher loads the fresh value of a cache key after the underlying rows changed.
+type Refresher func(ctx context.Context, key string, event *swekafka.CdcEventMsg) (value interface{}, expiration time.Duration, err error)
+
+// CacheRule maps a table to the cache key templates derived from its rows.
+// Empty Db, Schema or Table match any value. Keys use {column} placeholders, e.g. "user_permission:{user_id}".
+// Empty Ops match every operation, inserts included since they stale negative and list entries. A truncate
+// carries no row, so only the keys without placeholders are invalidated.
+type CacheRule struct {
+	Db        string                  `json:"db"`
+	Schema    string                  `json:"schema"`
+	Table     string                  `json:"table"`
+	Keys      []string                `json:"keys"`
+	Action    string                  `json:"action"`
+	Refresher string                  `json:"refresher"`
+	Ops       []swekafka.CdcOperation `json:"ops"`
+}
+
+// CacheInvalidator keeps cached entries consistent with the tables they are built from.
+type CacheInvalidator struct {
+	store      CacheStore
+	rules      []CacheRule
+	refreshers map[string]Refresher
+}
+
+// InvalidatorOption defines a function type for configuring the CacheInvalidator.
+type InvalidatorOption func(*CacheInvalidator)
+
+// WithRules appends the given rules to the invalidator.
+func WithRules(rules ...CacheRule) InvalidatorOption {
+	return func(i *CacheInvalidator) {
+		i.rules = append(i.rules, rules...)
+	}
+}
+
+// WithRefresher registers a refresher referenced by name from the rules.
+func WithRefresher(name string, refresher Refresher) InvalidatorOption {
+	return func(i *CacheInvalidator) {
+		i.refreshers[name] = refresher
+	}
+}
+
+// NewCacheInvalidator creates an invalidator with the rules declared under CDC_CACHE_RULES.
+func NewCacheInvalidator(store CacheStore, options ...InvalidatorOption) (*CacheInvalidator, error) {
+	rules, err := LoadCacheRules(config.GetString(KeyCacheRules, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	invalidator := &CacheInvalidator{
+		store:      store,
+		rules:      rules,
+		refreshers: make(map[string]Refresher),
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(invalidator)
+	}
+
+	for _, rule := range invalidator.rules {
+		if rule.Action == CacheActionRefresh {
+			if _, ok := invalidator.refreshers[rule.Refresher]; !ok {
+				return nil, fmt.Errorf("refresher %q not registered for table %s", rule.Refresher, rule.Table)
+			}
+		}
+	}
+
+	return invalidator, nil
+}
+
+// LoadCacheRules parses the JSON encoded cache rules. An empty value yields no rules.
+func LoadCacheRules(value string) ([]CacheRule, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var rules []CacheRule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("invalid cdc cache rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Handle decodes the Kafka message and invalidates or refreshes the cache keys of the matching rules.
+func (i *CacheInvalidator) Handle(ctx context.Context, msg kafka.Message) error {
+	event, err := Decode(msg.Value)
+	if errors.Is(err, ErrTombstone) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, rule := range i.rules {
+		if !rule.matches(event) {
+			continue
+		}
+
+		for _, key := range rule.keysFor(event) {
+			if err = i.apply(ctx, rule, key, event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Consumer adapts the invalidator to the handler signature accepted by KConsumer.Consume.
+func (i *CacheInvalidator) Consumer(ctx context.Context) func(msg kafka.Message) {
+	return func(msg kafka.Message) {
+		if err := i.Handle(ctx, msg); err != nil {
+			log.Printf("Error while invalidating cache: topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+		}
+	}
+}
+
+// apply invalidates or refreshes a single cache key.
+func (i *CacheInvalidator) apply(ctx context.Context, rule CacheRule, key string, event *swekafka.CdcEventMsg) error {
+	// A deleted or truncated row has nothing to refresh from.
+	if rule.Action != CacheActionRefresh || event.Op == swekafka.CdcOperationDelete ||
+		event.Op == swekafka.CdcOperationTruncate {
+		return i.store.Delete(key)
+	}
+
+	value, expiration, err := i.refreshers[rule.Refresher](ctx, key, event)
+	if err != nil {
+		log.Printf("failed to refresh cache for key %s, invalidating: %v", key, err)
+		return i.store.Delete(key)
+	}
+	return i.store.Set(key, value, expiration)
+}
+
+// matches checks if the rule applies to the source and operation of the event.
+func (r CacheRule) matches(event *swekafka.CdcEventMsg) bool {
+	if (r.Db != "" && r.Db != event.Source.Db) ||
+		(r.Schema != "" && r.Schema != event.Source.Schema) ||
+		(r.Table != "" && r.Table != event.Source.Table) {
+		return false
+	}
+
+	if len(r.Ops) == 0 {
+		return true
+	}
+
+	for _, op := range r.Ops {
+		if op == event.Op {
+			return true
+		}
+	}
+	return false
+}
+
+// keysFor renders the key templates against both row images, so that a key column change
+// invalidates the entry of the old row as well as the new one.
+func (r CacheRule) keysFor(event *swekafka.CdcEventMsg) []string {
+	seen := make(map[string]bool)
+	keys := make([]string, 0, len(r.Keys))
+
+	images := []map[string]interface{}{event.Before, event.After}
+	if event.Op == swekafka.CdcOperationTruncate {
+		images = []map[string]interface{}{{}}
+	}
+
+	for _, image := range images {
+		if image == nil {
+			continue
+		}
+
+		for _, template := range r.Keys {
+			key, ok := renderKey(template, image)
+			if !ok {
+				log.Printf("cannot render cache key %s for table %s: missing column", template, event.Source.Table)
+				continue
+			}
+
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
+
+// renderKey replaces the {column} placeholders of the template with the values of the row image.
+func renderKey(template string, image map[string]interface{}) (string, bool) {
+	ok := true
+	key := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, found := image[placeholder[1:len(placeholder)-1]]
+		if !found || value == nil {
+			ok = false
+			return placeholder
+		}
+		return fmt.Sprint(value)
+	})
+	return key, ok
+}
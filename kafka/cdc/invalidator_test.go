@@ -0,0 +1,116 @@
+package cdc
+
+import (
+	"context"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	set     map[string]interface{}
+	deleted []string
+}
+
+func (s *fakeStore) Set(key string, value interface{}, _ time.Duration) error {
+	s.set[key] = value
+	return nil
+}
+
+func (s *fakeStore) Delete(key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+// TestCacheInvalidator is a function to test CacheInvalidator.Handle function.
+func TestCacheInvalidator(t *testing.T) {
+	rules, err := LoadCacheRules(`[{"schema": "public", "table": "user_roles", "keys": ["user_permission:{user_id}"]}]`)
+	if err != nil {
+		t.Fatalf("LoadCacheRules failed: %v", err)
+	}
+
+	store := &fakeStore{set: make(map[string]interface{})}
+	invalidator, err := NewCacheInvalidator(store, WithRules(rules...))
+	if err != nil {
+		t.Fatalf("NewCacheInvalidator failed: %v", err)
+	}
+
+	update := `{"before": {"user_id": "u1"}, "after": {"user_id": "u2"}, "source": {"schema": "public", "table": "user_roles"}, "op": "u"}`
+	if err = invalidator.Handle(context.Background(), kafka.Message{Value: []byte(update)}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	sort.Strings(store.deleted)
+	expected := []string{"user_permission:u1", "user_permission:u2"}
+	if !reflect.DeepEqual(store.deleted, expected) {
+		t.Errorf("Handle failed: expected %v but got %v", expected, store.deleted)
+	}
+
+	// Inserts are matched by default, other tables are ignored.
+	store.deleted = nil
+	for _, value := range []string{
+		`{"after": {"user_id": "u3"}, "source": {"schema": "public", "table": "user_roles"}, "op": "c"}`,
+		`{"before": {"user_id": "u4"}, "source": {"schema": "public", "table": "users"}, "op": "d"}`,
+	} {
+		if err = invalidator.Handle(context.Background(), kafka.Message{Value: []byte(value)}); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+
+	if expected = []string{"user_permission:u3"}; !reflect.DeepEqual(store.deleted, expected) {
+		t.Errorf("Handle failed: expected %v but got %v", expected, store.deleted)
+	}
+}
+
+// TestCacheInvalidatorTruncate is a function to test that a truncate invalidates the keys without placeholders.
+func TestCacheInvalidatorTruncate(t *testing.T) {
+	store := &fakeStore{set: make(map[string]interface{})}
+	invalidator, err := NewCacheInvalidator(store,
+		WithRules(CacheRule{Table: "roles", Keys: []string{"roles:all", "role:{id}"}, Action: CacheActionRefresh, Refresher: "roles"}),
+		WithRefresher("roles", func(context.Context, string, *swekafka.CdcEventMsg) (interface{}, time.Duration, error) {
+			return "fresh", time.Minute, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewCacheInvalidator failed: %v", err)
+	}
+
+	truncate := `{"source": {"table": "roles"}, "op": "t"}`
+	if err = invalidator.Handle(context.Background(), kafka.Message{Value: []byte(truncate)}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	if expected := []string{"roles:all"}; !reflect.DeepEqual(store.deleted, expected) || len(store.set) != 0 {
+		t.Errorf("Handle failed: expected %v deleted but got set=%v deleted=%v", expected, store.set, store.deleted)
+	}
+}
+
+// TestCacheInvalidatorRefresh is a function to test the refresh action of CacheInvalidator.
+func TestCacheInvalidatorRefresh(t *testing.T) {
+	store := &fakeStore{set: make(map[string]interface{})}
+	invalidator, err := NewCacheInvalidator(store,
+		WithRules(CacheRule{Table: "user_roles", Keys: []string{"user_permission:{user_id}"}, Action: CacheActionRefresh, Refresher: "permissions"}),
+		WithRefresher("permissions", func(context.Context, string, *swekafka.CdcEventMsg) (interface{}, time.Duration, error) {
+			return "fresh", time.Minute, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewCacheInvalidator failed: %v", err)
+	}
+
+	update := `{"before": {"user_id": "u1"}, "after": {"user_id": "u1"}, "source": {"table": "user_roles"}, "op": "u"}`
+	if err = invalidator.Handle(context.Background(), kafka.Message{Value: []byte(update)}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	if store.set["user_permission:u1"] != "fresh" || len(store.deleted) != 0 {
+		t.Errorf("Handle failed: expected refreshed key but got set=%v deleted=%v", store.set, store.deleted)
+	}
+
+	if _, err = NewCacheInvalidator(store, WithRules(CacheRule{Table: "t", Action: CacheActionRefresh, Refresher: "missing"})); err == nil {
+		t.Error("NewCacheInvalidator failed: expected error for unregistered refresher")
+	}
+}
@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SASLMechanismPlain       = "PLAIN"
+	SASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	SASLMechanismScramSHA512 = "SCRAM-SHA-512"
+)
+
+// ClientConfig holds the connection settings shared by producers and consumers.
+type ClientConfig struct {
+	Brokers               []string
+	SASLMechanism         string
+	SASLUsername          string
+	SASLPassword          string
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+}
+
+// LoadClientConfig reads the connection settings from the KAFKA_* config keys.
+func LoadClientConfig() ClientConfig {
+	return ClientConfig{
+		Brokers:               strings.Split(config.GetString("KAFKA_BROKERS", "localhost:9092"), ","),
+		SASLMechanism:         config.GetString("KAFKA_SASL_MECHANISM", ""),
+		SASLUsername:          config.GetString("KAFKA_SASL_USERNAME", ""),
+		SASLPassword:          config.GetString("KAFKA_SASL_PASSWORD", ""),
+		TLSEnabled:            config.GetBool("KAFKA_TLS_ENABLED", false),
+		TLSCAFile:             config.GetString("KAFKA_TLS_CA_FILE", ""),
+		TLSCertFile:           config.GetString("KAFKA_TLS_CERT_FILE", ""),
+		TLSKeyFile:            config.GetString("KAFKA_TLS_KEY_FILE", ""),
+		TLSInsecureSkipVerify: config.GetBool("KAFKA_TLS_INSECURE_SKIP_VERIFY", false),
+	}
+}
+
+// Mechanism builds the SASL mechanism. Returns nil when SASL is not configured.
+func (c ClientConfig) Mechanism() (sasl.Mechanism, error) {
+	switch strings.ToUpper(c.SASLMechanism) {
+	case "":
+		return nil, nil
+	case SASLMechanismPlain:
+		return plain.Mechanism{Username: c.SASLUsername, Password: c.SASLPassword}, nil
+	case SASLMechanismScramSHA256:
+		return scram.Mechanism(scram.SHA256, c.SASLUsername, c.SASLPassword)
+	case SASLMechanismScramSHA512:
+		return scram.Mechanism(scram.SHA512, c.SASLUsername, c.SASLPassword)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism: %s", c.SASLMechanism)
+	}
+}
+
+// TLSConfig builds the TLS configuration. Returns nil when TLS is not enabled.
+func (c ClientConfig) TLSConfig() (*tls.Config, error) {
+	if !c.TLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+
+	if c.TLSCAFile != "" {
+		ca, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid CA file: %s", c.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLSCertFile != "" && c.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Dialer builds the dialer used by readers and admin connections.
+func (c ClientConfig) Dialer() (*kafka.Dialer, error) {
+	mechanism, err := c.Mechanism()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+		TLS:           tlsConfig,
+	}, nil
+}
+
+// Transport builds the transport used by writers and clients.
+func (c ClientConfig) Transport() (*kafka.Transport, error) {
+	mechanism, err := c.Mechanism()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		SASL: mechanism,
+		TLS:  tlsConfig,
+	}, nil
+}
@@ -0,0 +1,194 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	batchRetryInitialBackoff = 500 * time.Millisecond
+	batchRetryMaxBackoff     = 30 * time.Second
+)
+
+// batchFetcher fetches and commits messages explicitly. kafka.Reader satisfies it.
+type batchFetcher interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// ConsumeBatch accumulates the messages of each partition and passes them to the handler once maxSize messages
+// are pending or maxWait elapsed since the first one. Offsets are committed only after the handler succeeds;
+// a failing batch is retried with backoff, holding back the consumer until it succeeds or the context is done.
+// Pending batches are left uncommitted on shutdown and delivered again on the next start.
+// A non-positive maxSize or maxWait is replaced by KAFKA_BATCH_MAX_SIZE or KAFKA_BATCH_MAX_WAIT_MS.
+func (k *KConsumer) ConsumeBatch(ctx context.Context, maxSize int, maxWait time.Duration, handler func(msgs []kafka.Message) error) {
+	maxSize, maxWait = batchLimits(maxSize, maxWait)
+
+	log.Printf("Starting batch consumer for topic: %s with groupID: %s, maxSize: %d, maxWait: %s",
+		k.Reader.Config().Topic, k.Reader.Config().GroupID, maxSize, maxWait)
+
+	if err := consumeBatch(ctx, k.Reader, k.tracker(), maxSize, maxWait, handler); err != nil {
+		log.Printf("Error while consuming message: %v", err)
+	}
+}
+
+// batchLimits replaces the non-positive limits by their configured defaults.
+func batchLimits(maxSize int, maxWait time.Duration) (int, time.Duration) {
+	if maxSize <= 0 {
+		log.Printf("Invalid batch max size %d, using the default", maxSize)
+		maxSize = max(config.GetInt("KAFKA_BATCH_MAX_SIZE", 100), 1)
+	}
+	if maxWait <= 0 {
+		log.Printf("Invalid batch max wait %s, using the default", maxWait)
+		maxWait = time.Duration(max(config.GetInt("KAFKA_BATCH_MAX_WAIT_MS", 1000), 1)) * time.Millisecond
+	}
+	return maxSize, maxWait
+}
+
+// partitionBatch is the pending batch of a partition.
+type partitionBatch struct {
+	msgs     []kafka.Message
+	deadline time.Time
+}
+
+// consumeBatch runs the batching loop until the context is done or fetching fails.
+func consumeBatch(ctx context.Context, fetcher batchFetcher, tracker *statsTracker, maxSize int, maxWait time.Duration,
+	handler func(msgs []kafka.Message) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fetched := make(chan kafka.Message)
+	fetchErr := make(chan error, 1)
+
+	go func() {
+		for {
+			msg, err := fetcher.FetchMessage(ctx)
+			if err != nil {
+				fetchErr <- err
+				return
+			}
+
+			select {
+			case fetched <- msg:
+			case <-ctx.Done():
+				fetchErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	batches := make(map[int]*partitionBatch)
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case err := <-fetchErr:
+			return err
+
+		case msg := <-fetched:
+			batch, ok := batches[msg.Partition]
+			if !ok {
+				batch = &partitionBatch{deadline: time.Now().Add(maxWait)}
+				batches[msg.Partition] = batch
+			}
+
+			batch.msgs = append(batch.msgs, msg)
+			if len(batch.msgs) >= maxSize {
+				if err := flushBatch(ctx, fetcher, tracker, batch.msgs, handler); err != nil {
+					return err
+				}
+				delete(batches, msg.Partition)
+			}
+
+		case <-timer.C:
+			now := time.Now()
+			for partition, batch := range batches {
+				if now.Before(batch.deadline) {
+					continue
+				}
+
+				if err := flushBatch(ctx, fetcher, tracker, batch.msgs, handler); err != nil {
+					return err
+				}
+				delete(batches, partition)
+			}
+		}
+
+		resetTimer(timer, nextDeadline(batches, maxWait))
+	}
+}
+
+// flushBatch passes the batch to the handler until it succeeds, then commits its offsets.
+func flushBatch(ctx context.Context, fetcher batchFetcher, tracker *statsTracker, msgs []kafka.Message,
+	handler func(msgs []kafka.Message) error) error {
+	backoff := batchRetryInitialBackoff
+	tracker.start(time.Now())
+
+	for {
+		err := handleBatch(msgs, handler)
+		if err == nil {
+			break
+		}
+
+		first := msgs[0]
+		log.Printf("Error while handling batch: topic=%s partition=%d offsets=%d-%d, retrying in %s: %v",
+			first.Topic, first.Partition, first.Offset, msgs[len(msgs)-1].Offset, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(2*backoff, batchRetryMaxBackoff)
+	}
+
+	now := time.Now()
+	for _, msg := range msgs {
+		tracker.observe(msg, nil, now)
+	}
+
+	if err := fetcher.CommitMessages(ctx, msgs[len(msgs)-1]); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// handleBatch calls the handler, converting a panic into an error.
+func handleBatch(msgs []kafka.Message, handler func(msgs []kafka.Message) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while handling batch: %v\nStack: %s", r, string(debug.Stack()))
+		}
+	}()
+
+	return handler(msgs)
+}
+
+// nextDeadline returns the time until the earliest batch deadline, or maxWait without pending batches.
+func nextDeadline(batches map[int]*partitionBatch, maxWait time.Duration) time.Duration {
+	wait := maxWait
+	for _, batch := range batches {
+		if until := time.Until(batch.deadline); until < wait {
+			wait = until
+		}
+	}
+	return max(wait, 0)
+}
+
+// resetTimer resets the timer, draining a pending expiry.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFetcher serves a fixed list of messages and records the commits.
+type fakeFetcher struct {
+	mu        sync.Mutex
+	msgs      []kafka.Message
+	committed []kafka.Message
+}
+
+func (f *fakeFetcher) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	if len(f.msgs) > 0 {
+		msg := f.msgs[0]
+		f.msgs = f.msgs[1:]
+		f.mu.Unlock()
+		return msg, nil
+	}
+	f.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (f *fakeFetcher) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.committed = append(f.committed, msgs...)
+	return nil
+}
+
+func (f *fakeFetcher) commits() []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kafka.Message(nil), f.committed...)
+}
+
+// TestConsumeBatch is a function to test the per-partition batching and commits of consumeBatch.
+func TestConsumeBatch(t *testing.T) {
+	fetcher := &fakeFetcher{msgs: []kafka.Message{
+		{Partition: 0, Offset: 0},
+		{Partition: 1, Offset: 0},
+		{Partition: 0, Offset: 1},
+		{Partition: 0, Offset: 2},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var batches [][]kafka.Message
+	failures := 1
+
+	go func() {
+		_ = consumeBatch(ctx, fetcher, newStatsTracker(), 2, 50*time.Millisecond, func(msgs []kafka.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			// The first batch fails once and is retried before being committed.
+			if failures > 0 {
+				failures--
+				return errors.New("database unavailable")
+			}
+
+			batches = append(batches, msgs)
+			if len(batches) == 3 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatalf("ConsumeBatch failed: timed out with batches %v", batches)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Partition 0 fills a batch of 2, the remaining messages are flushed after maxWait.
+	if len(batches[0]) != 2 || batches[0][0].Partition != 0 || batches[0][1].Offset != 1 {
+		t.Errorf("ConsumeBatch failed: unexpected first batch %v", batches[0])
+	}
+	for _, batch := range batches[1:] {
+		if len(batch) != 1 {
+			t.Errorf("ConsumeBatch failed: expected a batch of %d but got %v", 1, batch)
+		}
+	}
+
+	if commits := fetcher.commits(); len(commits) < 2 || commits[0].Offset != 1 {
+		t.Errorf("ConsumeBatch failed: unexpected commits %v", commits)
+	}
+}
+
+// TestBatchLimits is a function to test the defaults of non-positive batch limits.
+func TestBatchLimits(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxSize         int
+		maxWait         time.Duration
+		expectedSize    int
+		expectedMaxWait time.Duration
+	}{
+		{name: "valid", maxSize: 10, maxWait: time.Minute, expectedSize: 10, expectedMaxWait: time.Minute},
+		{name: "zero size", maxSize: 0, maxWait: time.Minute, expectedSize: 100, expectedMaxWait: time.Minute},
+		{name: "negative size", maxSize: -1, maxWait: time.Minute, expectedSize: 100, expectedMaxWait: time.Minute},
+		{name: "zero wait", maxSize: 10, maxWait: 0, expectedSize: 10, expectedMaxWait: time.Second},
+		{name: "negative wait", maxSize: 10, maxWait: -time.Second, expectedSize: 10, expectedMaxWait: time.Second},
+	}
+
+	for _, test := range tests {
+		maxSize, maxWait := batchLimits(test.maxSize, test.maxWait)
+		if maxSize != test.expectedSize || maxWait != test.expectedMaxWait {
+			t.Errorf("batchLimits failed for %s: expected %d, %s but got %d, %s",
+				test.name, test.expectedSize, test.expectedMaxWait, maxSize, maxWait)
+		}
+	}
+}
@@ -2,45 +2,102 @@ package consumer
 
 import (
 	"context"
-	"github.com/ngdangkietswe/swe-go-common-shared/config"
 	"github.com/segmentio/kafka-go"
 	"log"
-	"strings"
+	"sync"
 	"time"
 )
 
+// Subscriber consumes the messages of a topic. KConsumer and kafkatest.Subscriber satisfy it.
+type Subscriber interface {
+	Consume(ctx context.Context, handler func(msg kafka.Message))
+	ConsumeWith(ctx context.Context, handler HandlerFunc, middlewares ...Middleware)
+}
+
 type KConsumer struct {
 	Reader *kafka.Reader
+
+	statsOnce sync.Once
+	stats     *statsTracker
 }
 
-func NewKConsumer(topic string) *KConsumer {
+// NewKConsumer creates a consumer of the topic configured from the KAFKA_* config keys and the given options.
+func NewKConsumer(topic string, options ...Option) *KConsumer {
+	readerConfig := newReaderConfig(options...)
+	readerConfig.Topic = topic
+
 	return &KConsumer{
-		Reader: kafka.NewReader(kafka.ReaderConfig{
-			Brokers:  strings.Split(config.GetString("KAFKA_BROKERS", "localhost:9092"), ","),
-			Topic:    topic,
-			GroupID:  config.GetString("KAFKA_CONSUMER_GROUP", "swe-consumer-group"),
-			MinBytes: 10e3,                   // 10KB
-			MaxBytes: 10e6,                   // 10MB
-			MaxWait:  500 * time.Millisecond, // 500ms
-		}),
+		Reader: kafka.NewReader(readerConfig),
 	}
 }
 
 // Consume continuously listens for messages from the Kafka topic.
 func (k *KConsumer) Consume(ctx context.Context, handler func(msg kafka.Message)) {
+	k.ConsumeWith(ctx, func(_ context.Context, msg kafka.Message) error {
+		handler(msg)
+		return nil
+	})
+}
+
+// ConsumeWith continuously listens for messages from the Kafka topic and passes them through the middlewares
+// to the handler. Panics are always recovered, so a failing message does not stop the consumer.
+func (k *KConsumer) ConsumeWith(ctx context.Context, handler HandlerFunc, middlewares ...Middleware) {
 	log.Printf("Starting consumer for topic: %s with groupID: %s, brokers: %s",
 		k.Reader.Config().Topic, k.Reader.Config().GroupID, k.Reader.Config().Brokers)
 
+	handler = Chain(handler, append([]Middleware{k.tracker().middleware(), Recovery()}, middlewares...)...)
+
 	for {
 		msg, err := k.Reader.ReadMessage(ctx)
 		if err != nil {
 			log.Printf("Error while consuming message: %v", err)
 			break
 		}
-		log.Printf("Received message: topic=%s partition=%d offset=%d key=%s value=%s",
-			msg.Topic, msg.Partition, msg.Offset, string(msg.Key), string(msg.Value))
 
 		// Call the handler to process the message
-		handler(msg)
+		if err = handler(ctx, msg); err != nil {
+			log.Printf("Error while handling message: topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+		}
+	}
+}
+
+// Stats returns the per-partition lag, last processed offset and processing rate since the previous call,
+// merged with the counters of kafka.Reader.Stats.
+func (k *KConsumer) Stats() Stats {
+	stats := k.tracker().snapshot(k.Reader.Stats(), time.Now())
+	stats.GroupId = k.Reader.Config().GroupID
+	return stats
+}
+
+// ReportStats passes a stats snapshot to the hook at every interval until the context is done.
+func (k *KConsumer) ReportStats(ctx context.Context, interval time.Duration, hook StatsHook) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hook(k.Stats())
+		}
 	}
 }
+
+// Health reports the consumer unhealthy when its lag, idle time or the run time of its handler exceeds the thresholds.
+// Unlike Stats, it does not reset the processing rates, so that health checks do not skew the reported rates.
+func (k *KConsumer) Health(thresholds HealthThresholds) ConsumerHealth {
+	now := time.Now()
+	stats := k.tracker().peek(k.Reader.Stats(), now)
+	stats.GroupId = k.Reader.Config().GroupID
+	return evaluate(stats, thresholds, now)
+}
+
+// tracker returns the stats tracker, created on first use so that a KConsumer built without NewKConsumer works.
+func (k *KConsumer) tracker() *statsTracker {
+	k.statsOnce.Do(func() {
+		k.stats = newStatsTracker()
+	})
+	return k.stats
+}
@@ -0,0 +1,23 @@
+package consumer
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"log"
+)
+
+// HandlerFunc processes a consumed message and reports whether it succeeded.
+type HandlerFunc func(ctx context.Context, msg kafka.Message) error
+
+// Middleware wraps a HandlerFunc with cross-cutting behaviour.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Adapt converts a HandlerFunc to the handler signature accepted by Consume, logging its errors.
+func Adapt(ctx context.Context, handler HandlerFunc) func(msg kafka.Message) {
+	return func(msg kafka.Message) {
+		if err := handler(ctx, msg); err != nil {
+			log.Printf("Error while handling message: topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrInProgress is returned by DedupStore.Claim while another consumer processes the message.
+var ErrInProgress = errors.New("message is being processed")
+
+// DedupStore records the IDs of messages being processed and processed.
+type DedupStore interface {
+	// Claim atomically records the ID as processing for the lease, so that a crashed consumer does not hold it longer.
+	// Returns false if the ID was already processed and ErrInProgress while it is processing.
+	Claim(ctx context.Context, id string, lease time.Duration) (bool, error)
+
+	// Complete records the ID as processed for the retention window.
+	Complete(ctx context.Context, id string, retention time.Duration) error
+
+	// Release forgets the ID so that the message can be processed again.
+	Release(ctx context.Context, id string) error
+}
+
+// KeyFunc derives the deduplication ID of a message.
+type KeyFunc func(msg kafka.Message) string
+
+type idempotency struct {
+	lease     time.Duration
+	retention time.Duration
+	keyFunc   KeyFunc
+}
+
+// IdempotencyOption defines a function type for configuring the Idempotent middleware.
+type IdempotencyOption func(*idempotency)
+
+// WithRetention sets how long processed IDs are remembered.
+func WithRetention(retention time.Duration) IdempotencyOption {
+	return func(i *idempotency) {
+		i.retention = retention
+	}
+}
+
+// WithLease sets how long a message is claimed while its handler runs.
+// It should exceed the longest handler run, after which a redelivery may process the message again.
+func WithLease(lease time.Duration) IdempotencyOption {
+	return func(i *idempotency) {
+		i.lease = lease
+	}
+}
+
+// WithKeyFunc sets how the deduplication ID is derived from a message.
+func WithKeyFunc(keyFunc KeyFunc) IdempotencyOption {
+	return func(i *idempotency) {
+		i.keyFunc = keyFunc
+	}
+}
+
+// Idempotent returns a middleware that skips messages already processed within the retention window.
+// The ID is claimed for the lease before the handler runs, and completed for the retention window when it succeeds.
+// If the handler fails or panics the ID is released, so a redelivery is retried. A redelivery while the message
+// is processing returns ErrInProgress.
+func Idempotent(store DedupStore, options ...IdempotencyOption) Middleware {
+	i := &idempotency{
+		lease:     time.Duration(config.GetInt("KAFKA_DEDUP_LEASE", 300)) * time.Second,
+		retention: time.Duration(config.GetInt("KAFKA_DEDUP_RETENTION", 86400)) * time.Second,
+		keyFunc:   EventIdKey,
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(i)
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg kafka.Message) error {
+			id := i.keyFunc(msg)
+
+			claimed, err := store.Claim(ctx, id, i.lease)
+			if err != nil {
+				return fmt.Errorf("failed to claim message %s: %w", id, err)
+			}
+
+			if !claimed {
+				log.Printf("Skipping duplicate message: topic=%s partition=%d offset=%d id=%s",
+					msg.Topic, msg.Partition, msg.Offset, id)
+				return nil
+			}
+
+			succeeded := false
+			defer func() {
+				if succeeded {
+					return
+				}
+				if releaseErr := store.Release(context.WithoutCancel(ctx), id); releaseErr != nil {
+					log.Printf("failed to release message %s: %v", id, releaseErr)
+				}
+			}()
+
+			if err = next(ctx, msg); err != nil {
+				return err
+			}
+			succeeded = true
+
+			// The message was handled, a failed completion only leaves the claim to expire with the lease.
+			if err = store.Complete(ctx, id, i.retention); err != nil {
+				log.Printf("failed to complete message %s: %v", id, err)
+			}
+			return nil
+		}
+	}
+}
+
+// EventIdKey uses the event-id header set by KProducer, falling back to OffsetKey.
+func EventIdKey(msg kafka.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == constants.KafkaHeaderEventId && len(header.Value) > 0 {
+			return msg.Topic + ":" + string(header.Value)
+		}
+	}
+	return OffsetKey(msg)
+}
+
+// OffsetKey identifies a message by its topic, partition and offset.
+func OffsetKey(msg kafka.Message) string {
+	return fmt.Sprintf("%s:%d:%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// Values of the records of the RedisDedupStore.
+const (
+	dedupProcessing = "processing"
+	dedupDone       = "done"
+)
+
+// RedisDedupStore is a DedupStore backed by the shared Redis cache.
+type RedisDedupStore struct {
+	cache *cache.RedisCache
+}
+
+func NewRedisDedupStore(cache *cache.RedisCache) *RedisDedupStore {
+	return &RedisDedupStore{cache: cache}
+}
+
+// Claim records the ID as processing with SET NX so that concurrent consumers cannot both claim it.
+func (s *RedisDedupStore) Claim(_ context.Context, id string, lease time.Duration) (bool, error) {
+	claimed, err := s.cache.SetNX(s.key(id), dedupProcessing, lease)
+	if err != nil || claimed {
+		return claimed, err
+	}
+
+	var status string
+	if err = s.cache.Get(s.key(id), &status); err != nil {
+		return false, err
+	}
+	if status == dedupProcessing {
+		return false, ErrInProgress
+	}
+	return false, nil
+}
+
+// Complete records the ID as done for the retention window.
+func (s *RedisDedupStore) Complete(_ context.Context, id string, retention time.Duration) error {
+	return s.cache.Set(s.key(id), dedupDone, retention)
+}
+
+// Release removes the ID from Redis.
+func (s *RedisDedupStore) Release(_ context.Context, id string) error {
+	return s.cache.Delete(s.key(id))
+}
+
+func (s *RedisDedupStore) key(id string) string {
+	return fmt.Sprintf("%s:%s", constants.KafkaDedupCacheKeyPrefix, id)
+}
+
+// MemoryDedupStore is an in-process DedupStore, suitable for a single consumer instance and tests.
+type MemoryDedupStore struct {
+	mu       sync.Mutex
+	records  map[string]dedupRecord
+	purgedAt time.Time
+}
+
+type dedupRecord struct {
+	done    bool
+	expires time.Time
+}
+
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{records: make(map[string]dedupRecord)}
+}
+
+// Claim records the ID as processing unless it is already present and not yet expired.
+func (s *MemoryDedupStore) Claim(_ context.Context, id string, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if record, ok := s.records[id]; ok && now.Before(record.expires) {
+		if !record.done {
+			return false, ErrInProgress
+		}
+		return false, nil
+	}
+
+	s.purge(now)
+	s.records[id] = dedupRecord{expires: now.Add(lease)}
+	return true, nil
+}
+
+// Complete records the ID as done for the retention window.
+func (s *MemoryDedupStore) Complete(_ context.Context, id string, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[id] = dedupRecord{done: true, expires: time.Now().Add(retention)}
+	return nil
+}
+
+// Release removes the ID from the store.
+func (s *MemoryDedupStore) Release(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, id)
+	return nil
+}
+
+// purge drops the expired IDs, at most once per minute. The caller must hold the lock.
+func (s *MemoryDedupStore) purge(now time.Time) {
+	if now.Sub(s.purgedAt) < time.Minute {
+		return
+	}
+	s.purgedAt = now
+
+	for id, record := range s.records {
+		if !now.Before(record.expires) {
+			delete(s.records, id)
+		}
+	}
+}
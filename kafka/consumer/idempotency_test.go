@@ -0,0 +1,126 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// TestIdempotent is a function to test Idempotent function.
+func TestIdempotent(t *testing.T) {
+	calls := 0
+	fail := false
+	handler := Idempotent(NewMemoryDedupStore(), WithRetention(time.Minute))(func(context.Context, kafka.Message) error {
+		calls++
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	msg := kafka.Message{
+		Topic:   constants.TopicResetPassword,
+		Offset:  1,
+		Headers: []kafka.Header{{Key: constants.KafkaHeaderEventId, Value: []byte("event-1")}},
+	}
+
+	// A retried produce yields the same event ID at a different offset.
+	retried := msg
+	retried.Offset = 2
+
+	for _, m := range []kafka.Message{msg, retried, msg} {
+		if err := handler(context.Background(), m); err != nil {
+			t.Fatalf("Idempotent failed: %v", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Idempotent failed: expected %d calls but got %d", 1, calls)
+	}
+
+	// A failed message is released and processed again on redelivery.
+	fail = true
+	failed := kafka.Message{Topic: constants.TopicResetPassword, Offset: 3}
+	if err := handler(context.Background(), failed); err == nil {
+		t.Fatal("Idempotent failed: expected handler error")
+	}
+
+	fail = false
+	if err := handler(context.Background(), failed); err != nil || calls != 3 {
+		t.Errorf("Idempotent failed: expected %d calls but got %d (err=%v)", 3, calls, err)
+	}
+}
+
+// TestMemoryDedupStore is a function to test the retention of MemoryDedupStore.
+func TestMemoryDedupStore(t *testing.T) {
+	store := NewMemoryDedupStore()
+
+	if ok, _ := store.Claim(context.Background(), "id", time.Millisecond); !ok {
+		t.Fatal("Claim failed: expected first claim to succeed")
+	}
+
+	if ok, _ := store.Claim(context.Background(), "id", time.Millisecond); ok {
+		t.Error("Claim failed: expected duplicate claim to be rejected")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if ok, _ := store.Claim(context.Background(), "id", time.Millisecond); !ok {
+		t.Error("Claim failed: expected claim to succeed after retention")
+	}
+}
+
+// TestIdempotentPanic is a function to test that a panicking handler releases the message.
+func TestIdempotentPanic(t *testing.T) {
+	store := NewMemoryDedupStore()
+	handler := Idempotent(store)(func(context.Context, kafka.Message) error {
+		panic("boom")
+	})
+
+	msg := kafka.Message{Topic: constants.TopicResetPassword, Offset: 1}
+	func() {
+		defer func() { _ = recover() }()
+		_ = handler(context.Background(), msg)
+	}()
+
+	if ok, err := store.Claim(context.Background(), OffsetKey(msg), time.Minute); !ok || err != nil {
+		t.Errorf("Idempotent failed: expected the message to be released but got %v, %v", ok, err)
+	}
+}
+
+// TestIdempotentLease is a function to test the processing and done records of a message.
+func TestIdempotentLease(t *testing.T) {
+	store := NewMemoryDedupStore()
+	msg := kafka.Message{Topic: constants.TopicResetPassword, Offset: 1}
+
+	var duplicate error
+	handler := Idempotent(store, WithLease(time.Minute), WithRetention(time.Hour))
+	processing := handler(func(ctx context.Context, msg kafka.Message) error {
+		// A redelivery while the message is processing is not skipped but failed, so that it is retried.
+		duplicate = handler(func(context.Context, kafka.Message) error { return nil })(ctx, msg)
+		return nil
+	})
+
+	if err := processing(context.Background(), msg); err != nil {
+		t.Fatalf("Idempotent failed: %v", err)
+	}
+	if !errors.Is(duplicate, ErrInProgress) {
+		t.Errorf("Idempotent failed: expected %v but got %v", ErrInProgress, duplicate)
+	}
+
+	// The processed message is remembered for the retention window.
+	if ok, err := store.Claim(context.Background(), OffsetKey(msg), time.Minute); ok || err != nil {
+		t.Errorf("Claim failed: expected a processed message but got %v, %v", ok, err)
+	}
+
+	// A processing claim of a crashed consumer expires with the lease.
+	if ok, _ := store.Claim(context.Background(), "crashed", time.Millisecond); !ok {
+		t.Fatal("Claim failed: expected first claim to succeed")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if ok, err := store.Claim(context.Background(), "crashed", time.Millisecond); !ok || err != nil {
+		t.Errorf("Claim failed: expected claim to succeed after the lease but got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,207 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/logger"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const redactedValue = "***"
+
+// DefaultRedactFields are the JSON fields masked when message values are logged.
+var DefaultRedactFields = []string{"email", "password", "token", "secret"}
+
+// DefaultPropagatedHeaders are the headers copied into the handler context by Propagation.
+var DefaultPropagatedHeaders = []string{
+	constants.KafkaHeaderEventId,
+	constants.KafkaHeaderTraceParent,
+	constants.KafkaHeaderCorrelationId,
+}
+
+// MetricsRecorder receives the processing metrics of consumed messages.
+type MetricsRecorder interface {
+	ObserveLatency(topic string, partition int, latency time.Duration, err error)
+	ObserveLag(topic string, partition int, lag int64)
+}
+
+type headersCtxKey struct{}
+
+// Chain composes the middlewares around the handler. The first middleware is the outermost one.
+func Chain(handler HandlerFunc, middlewares ...Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Recovery returns a middleware that converts a panicking handler into an error.
+func Recovery() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg kafka.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic while handling message: %v\nStack: %s", r, string(debug.Stack()))
+				}
+			}()
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+type loggingOptions struct {
+	logValue     bool
+	redactFields []string
+}
+
+// LoggingOption defines a function type for configuring the Logging middleware.
+type LoggingOption func(*loggingOptions)
+
+// WithValue logs the message value with the default and the given JSON fields redacted.
+func WithValue(redactFields ...string) LoggingOption {
+	return func(o *loggingOptions) {
+		o.logValue = true
+		o.redactFields = append(o.redactFields, redactFields...)
+	}
+}
+
+// Logging returns a middleware that logs the outcome of each message. The value is omitted unless WithValue is set.
+func Logging(l *logger.Logger, options ...LoggingOption) Middleware {
+	opts := &loggingOptions{redactFields: DefaultRedactFields}
+
+	// Apply custom options
+	for _, option := range options {
+		option(opts)
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg kafka.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+
+			fields := []zap.Field{
+				zap.String("topic", msg.Topic),
+				zap.Int("partition", msg.Partition),
+				zap.Int64("offset", msg.Offset),
+				zap.ByteString("key", msg.Key),
+				zap.Duration("latency", time.Since(start)),
+			}
+
+			if opts.logValue {
+				fields = append(fields, zap.String("value", Redact(msg.Value, opts.redactFields...)))
+			}
+
+			if err != nil {
+				l.Error("Error while handling message", append(fields, zap.Error(err))...)
+			} else {
+				l.Debug("Message handled", fields...)
+			}
+
+			return err
+		}
+	}
+}
+
+// Metrics returns a middleware that reports the handler latency and the partition lag of each message.
+func Metrics(recorder MetricsRecorder) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg kafka.Message) error {
+			if msg.HighWaterMark > 0 {
+				recorder.ObserveLag(msg.Topic, msg.Partition, msg.HighWaterMark-msg.Offset-1)
+			}
+
+			start := time.Now()
+			err := next(ctx, msg)
+			recorder.ObserveLatency(msg.Topic, msg.Partition, time.Since(start), err)
+
+			return err
+		}
+	}
+}
+
+// Propagation returns a middleware that copies the given headers into the handler context.
+// Without arguments DefaultPropagatedHeaders are used.
+func Propagation(headers ...string) Middleware {
+	if len(headers) == 0 {
+		headers = DefaultPropagatedHeaders
+	}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg kafka.Message) error {
+			values := make(map[string]string)
+			for _, header := range msg.Headers {
+				for _, key := range headers {
+					if strings.EqualFold(header.Key, key) {
+						values[key] = string(header.Value)
+					}
+				}
+			}
+
+			if len(values) > 0 {
+				ctx = context.WithValue(ctx, headersCtxKey{}, values)
+			}
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+// HeaderFromContext returns the value of a header propagated into the context.
+func HeaderFromContext(ctx context.Context, key string) (string, bool) {
+	values, ok := ctx.Value(headersCtxKey{}).(map[string]string)
+	if !ok {
+		return "", false
+	}
+
+	value, ok := values[key]
+	return value, ok
+}
+
+// Redact masks the given fields of a JSON message value. Values that are not JSON are replaced by their size.
+func Redact(value []byte, fields ...string) string {
+	var doc interface{}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(value))
+	}
+
+	bytes, err := json.Marshal(redact(doc, fields))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes>", len(value))
+	}
+	return string(bytes)
+}
+
+// redact masks the fields of the JSON document recursively.
+func redact(doc interface{}, fields []string) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if containsFold(fields, key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redact(value, fields)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value, fields)
+		}
+	}
+	return doc
+}
+
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestChain is a function to test Chain function.
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, msg kafka.Message) error {
+				order = append(order, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+
+	handler := Chain(func(context.Context, kafka.Message) error {
+		order = append(order, "handler")
+		return nil
+	}, trace("first"), trace("second"))
+
+	if err := handler(context.Background(), kafka.Message{}); err != nil {
+		t.Fatalf("Chain failed: %v", err)
+	}
+
+	expected := []string{"first", "second", "handler"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Chain failed: expected %v but got %v", expected, order)
+	}
+}
+
+// TestRecovery is a function to test Recovery function.
+func TestRecovery(t *testing.T) {
+	handler := Chain(func(context.Context, kafka.Message) error {
+		panic("boom")
+	}, Recovery())
+
+	if err := handler(context.Background(), kafka.Message{}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Recovery failed: expected panic error but got %v", err)
+	}
+}
+
+// TestRedact is a function to test Redact function.
+func TestRedact(t *testing.T) {
+	value, _ := json.Marshal(domain.RegisterUser{Username: "john", Email: "john@example.com"})
+
+	redacted := Redact(value, DefaultRedactFields...)
+	if strings.Contains(redacted, "john@example.com") || !strings.Contains(redacted, "john") {
+		t.Errorf("Redact failed: unexpected value %s", redacted)
+	}
+
+	if redacted = Redact([]byte("not json")); redacted != "<8 bytes>" {
+		t.Errorf("Redact failed: expected %v but got %v", "<8 bytes>", redacted)
+	}
+}
+
+// TestPropagation is a function to test Propagation function.
+func TestPropagation(t *testing.T) {
+	var correlationId string
+	handler := Chain(func(ctx context.Context, _ kafka.Message) error {
+		correlationId, _ = HeaderFromContext(ctx, constants.KafkaHeaderCorrelationId)
+		return nil
+	}, Propagation())
+
+	msg := kafka.Message{Headers: []kafka.Header{{Key: constants.KafkaHeaderCorrelationId, Value: []byte("abc")}}}
+	if err := handler(context.Background(), msg); err != nil || correlationId != "abc" {
+		t.Errorf("Propagation failed: expected %v but got %v (err=%v)", "abc", correlationId, err)
+	}
+}
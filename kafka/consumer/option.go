@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"crypto/tls"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"time"
+)
+
+// Option defines a function type for configuring the reader of a KConsumer or Router.
+type Option func(*kafka.ReaderConfig)
+
+// WithBrokers sets the brokers of the reader.
+func WithBrokers(brokers ...string) Option {
+	return func(c *kafka.ReaderConfig) {
+		c.Brokers = brokers
+	}
+}
+
+// WithGroupId sets the consumer group of the reader.
+func WithGroupId(groupId string) Option {
+	return func(c *kafka.ReaderConfig) {
+		c.GroupID = groupId
+	}
+}
+
+// WithStartOffset sets where a group without committed offsets starts: kafka.FirstOffset or kafka.LastOffset.
+func WithStartOffset(offset int64) Option {
+	return func(c *kafka.ReaderConfig) {
+		c.StartOffset = offset
+	}
+}
+
+// WithFetchBytes sets the minimum and maximum number of bytes fetched per request.
+func WithFetchBytes(minBytes, maxBytes int) Option {
+	return func(c *kafka.ReaderConfig) {
+		c.MinBytes = minBytes
+		c.MaxBytes = maxBytes
+	}
+}
+
+// WithMaxWait sets how long a fetch waits for MinBytes to become available.
+func WithMaxWait(maxWait time.Duration) Option {
+	return func(c *kafka.ReaderConfig) {
+		c.MaxWait = maxWait
+	}
+}
+
+// WithSASL sets the SASL mechanism used to authenticate against the brokers.
+func WithSASL(mechanism sasl.Mechanism) Option {
+	return func(c *kafka.ReaderConfig) {
+		dialer(c).SASLMechanism = mechanism
+	}
+}
+
+// WithTLS enables TLS with the given configuration.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(c *kafka.ReaderConfig) {
+		dialer(c).TLS = tlsConfig
+	}
+}
+
+// dialer returns the dialer of the reader config, creating it if needed.
+func dialer(c *kafka.ReaderConfig) *kafka.Dialer {
+	if c.Dialer == nil {
+		c.Dialer = &kafka.Dialer{
+			Timeout:   10 * time.Second,
+			DualStack: true,
+		}
+	}
+	return c.Dialer
+}
+
+// newReaderConfig creates a reader config from the KAFKA_* config keys and the given options.
+// It panics if the configured SASL or TLS settings are invalid.
+func newReaderConfig(options ...Option) kafka.ReaderConfig {
+	clientConfig := swekafka.LoadClientConfig()
+
+	readerDialer, err := clientConfig.Dialer()
+	if err != nil {
+		panic(err)
+	}
+
+	readerConfig := kafka.ReaderConfig{
+		Brokers:     clientConfig.Brokers,
+		GroupID:     config.GetString("KAFKA_CONSUMER_GROUP", "swe-consumer-group"),
+		MinBytes:    config.GetInt("KAFKA_CONSUMER_MIN_BYTES", 10e3),                                    // 10KB
+		MaxBytes:    config.GetInt("KAFKA_CONSUMER_MAX_BYTES", 10e6),                                    // 10MB
+		MaxWait:     time.Duration(config.GetInt("KAFKA_CONSUMER_MAX_WAIT_MS", 500)) * time.Millisecond, // 500ms
+		StartOffset: startOffset(config.GetString("KAFKA_CONSUMER_START_OFFSET", "first")),
+		Dialer:      readerDialer,
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(&readerConfig)
+	}
+
+	return readerConfig
+}
+
+// startOffset returns the start offset for the given name, defaulting to the first offset.
+func startOffset(name string) int64 {
+	if name == "last" {
+		return kafka.LastOffset
+	}
+	return kafka.FirstOffset
+}
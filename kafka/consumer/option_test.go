@@ -0,0 +1,83 @@
+package consumer
+
+import (
+	"github.com/segmentio/kafka-go"
+	"github.com/spf13/viper"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNewReaderConfig is a function to test the reader config built from missing, set and overridden config keys.
+func TestNewReaderConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		options  []Option
+		expected kafka.ReaderConfig
+	}{
+		{
+			name: "defaults",
+			expected: kafka.ReaderConfig{
+				Brokers:     []string{"localhost:9092"},
+				GroupID:     "swe-consumer-group",
+				MinBytes:    10e3,
+				MaxBytes:    10e6,
+				MaxWait:     500 * time.Millisecond,
+				StartOffset: kafka.FirstOffset,
+			},
+		},
+		{
+			name: "config keys",
+			settings: map[string]interface{}{
+				"KAFKA_BROKERS":               "kafka-1:9092,kafka-2:9092",
+				"KAFKA_CONSUMER_GROUP":        "billing",
+				"KAFKA_CONSUMER_MAX_WAIT_MS":  100,
+				"KAFKA_CONSUMER_START_OFFSET": "last",
+			},
+			expected: kafka.ReaderConfig{
+				Brokers:     []string{"kafka-1:9092", "kafka-2:9092"},
+				GroupID:     "billing",
+				MinBytes:    10e3,
+				MaxBytes:    10e6,
+				MaxWait:     100 * time.Millisecond,
+				StartOffset: kafka.LastOffset,
+			},
+		},
+		{
+			name:    "options",
+			options: []Option{WithBrokers("kafka:9092"), WithGroupId("audit"), WithFetchBytes(1, 2), WithStartOffset(kafka.LastOffset)},
+			expected: kafka.ReaderConfig{
+				Brokers:     []string{"kafka:9092"},
+				GroupID:     "audit",
+				MinBytes:    1,
+				MaxBytes:    2,
+				MaxWait:     500 * time.Millisecond,
+				StartOffset: kafka.LastOffset,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.settings {
+				viper.Set(key, value)
+			}
+			defer func() {
+				for key := range test.settings {
+					viper.Set(key, nil)
+				}
+			}()
+
+			actual := newReaderConfig(test.options...)
+			if actual.Dialer == nil || actual.Dialer.SASLMechanism != nil || actual.Dialer.TLS != nil {
+				t.Errorf("newReaderConfig failed: expected a plain dialer but got %+v", actual.Dialer)
+			}
+
+			actual.Dialer = nil
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("newReaderConfig failed: expected %+v but got %+v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/serde"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Router subscribes a consumer group to many topics and dispatches each message to the handler of its topic.
+type Router struct {
+	readerConfig kafka.ReaderConfig
+	handlers     map[string]HandlerFunc
+	patterns     []patternHandler
+	middlewares  []Middleware
+
+	// done is closed by Close to stop Run.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.RWMutex
+	running  bool
+	stopped  chan struct{}
+	lastErr  error
+	closeErr error
+	topics   map[string]*TopicHealth
+}
+
+type patternHandler struct {
+	pattern *regexp.Regexp
+	handler HandlerFunc
+}
+
+// RouterHealth is the aggregated health of a Router.
+type RouterHealth struct {
+	Healthy   bool                   `json:"healthy"`
+	Running   bool                   `json:"running"`
+	LastError string                 `json:"last_error,omitempty"`
+	Topics    map[string]TopicHealth `json:"topics"`
+}
+
+// TopicHealth is the processing status of a single topic.
+type TopicHealth struct {
+	Processed     int64     `json:"processed"`
+	Failed        int64     `json:"failed"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// NewRouter creates a router configured from the KAFKA_* config keys and the given options.
+func NewRouter(options ...Option) *Router {
+	return &Router{
+		readerConfig: newReaderConfig(options...),
+		handlers:     make(map[string]HandlerFunc),
+		done:         make(chan struct{}),
+		topics:       make(map[string]*TopicHealth),
+	}
+}
+
+// Handle registers the handler of a topic.
+func (r *Router) Handle(topic string, handler HandlerFunc) {
+	r.handlers[topic] = handler
+}
+
+// HandlePattern registers a handler for every topic matching the pattern when Run starts.
+// Handlers registered with Handle take precedence.
+func (r *Router) HandlePattern(pattern *regexp.Regexp, handler HandlerFunc) {
+	r.patterns = append(r.patterns, patternHandler{pattern: pattern, handler: handler})
+}
+
+// Use appends middlewares applied to every handler of the Router.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Typed adapts a handler of JSON encoded values of type T to a HandlerFunc.
+func Typed[T any](handler func(ctx context.Context, value T, msg kafka.Message) error) HandlerFunc {
+	return TypedWith(serde.JSON{}, handler)
+}
+
+// TypedWith adapts a handler of values of type T decoded by the deserializer to a HandlerFunc.
+func TypedWith[T any](deserializer serde.Deserializer, handler func(ctx context.Context, value T, msg kafka.Message) error) HandlerFunc {
+	return func(ctx context.Context, msg kafka.Message) error {
+		var value T
+		if err := deserializer.Deserialize(msg.Topic, msg.Value, &value); err != nil {
+			return fmt.Errorf("failed to deserialize message: %w", err)
+		}
+		return handler(ctx, value, msg)
+	}
+}
+
+// Run subscribes to the registered topics and consumes until the context is cancelled or the Router is closed.
+// The reader is closed when Run returns. Run returns immediately once the Router is closed.
+func (r *Router) Run(ctx context.Context) error {
+	topics, err := r.resolveTopics(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(topics) == 0 {
+		return errors.New("router has no topics to subscribe")
+	}
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	r.mu.Lock()
+	select {
+	case <-r.done:
+		r.mu.Unlock()
+		return nil
+	default:
+	}
+	r.running = true
+	r.stopped = stopped
+	r.mu.Unlock()
+
+	readerConfig := r.readerConfig
+	readerConfig.GroupTopics = topics
+	reader := kafka.NewReader(readerConfig)
+	defer func() {
+		if err := reader.Close(); err != nil {
+			r.mu.Lock()
+			r.closeErr = fmt.Errorf("failed to close router: %w", err)
+			r.mu.Unlock()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-r.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	log.Printf("Starting router for topics: %v with groupID: %s, brokers: %s",
+		topics, readerConfig.GroupID, readerConfig.Brokers)
+
+	middlewares := append([]Middleware{Recovery()}, r.middlewares...)
+	handlers := make(map[string]HandlerFunc, len(topics))
+	for _, topic := range topics {
+		handlers[topic] = Chain(r.handlerFor(topic), middlewares...)
+	}
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			// A cancelled context or a closed Router is a graceful shutdown.
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				r.stop(nil)
+				return nil
+			}
+			r.stop(err)
+			return fmt.Errorf("router stopped: %w", err)
+		}
+
+		r.record(msg.Topic, handlers[msg.Topic](ctx, msg))
+	}
+}
+
+// Close stops the Router. It waits for a running Run to finish its current message and close the reader,
+// so it must not be called from a handler. Closing a Router that is not running returns immediately.
+func (r *Router) Close() error {
+	r.mu.Lock()
+	r.closeOnce.Do(func() { close(r.done) })
+	stopped := r.stopped
+	r.mu.Unlock()
+
+	if stopped != nil {
+		<-stopped
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running = false
+	return r.closeErr
+}
+
+// Health returns the aggregated health of the Router. It is healthy while running and no topic failed
+// on its last message.
+func (r *Router) Health() RouterHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	health := RouterHealth{
+		Healthy: r.running,
+		Running: r.running,
+		Topics:  make(map[string]TopicHealth, len(r.topics)),
+	}
+
+	if r.lastErr != nil {
+		health.LastError = r.lastErr.Error()
+	}
+
+	for topic, status := range r.topics {
+		health.Topics[topic] = *status
+		if status.LastError != "" {
+			health.Healthy = false
+		}
+	}
+
+	return health
+}
+
+// resolveTopics returns the explicit topics and the existing topics matching the registered patterns.
+func (r *Router) resolveTopics(ctx context.Context) ([]string, error) {
+	var available []string
+	if len(r.patterns) > 0 {
+		client := &kafka.Client{
+			Addr: kafka.TCP(r.readerConfig.Brokers...),
+			Transport: &kafka.Transport{
+				SASL: r.readerConfig.Dialer.SASLMechanism,
+				TLS:  r.readerConfig.Dialer.TLS,
+			},
+		}
+		metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list topics: %w", err)
+		}
+
+		for _, topic := range metadata.Topics {
+			if !topic.Internal {
+				available = append(available, topic.Name)
+			}
+		}
+	}
+
+	return r.matchTopics(available), nil
+}
+
+// matchTopics returns the sorted explicit topics and the available topics matching a pattern.
+func (r *Router) matchTopics(available []string) []string {
+	seen := make(map[string]bool)
+	for topic := range r.handlers {
+		seen[topic] = true
+	}
+
+	for _, topic := range available {
+		for _, p := range r.patterns {
+			if p.pattern.MatchString(topic) {
+				seen[topic] = true
+			}
+		}
+	}
+
+	topics := make([]string, 0, len(seen))
+	for topic := range seen {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// handlerFor returns the handler registered for the topic, explicit handlers first.
+func (r *Router) handlerFor(topic string) HandlerFunc {
+	if handler, ok := r.handlers[topic]; ok {
+		return handler
+	}
+
+	for _, p := range r.patterns {
+		if p.pattern.MatchString(topic) {
+			return p.handler
+		}
+	}
+
+	return func(_ context.Context, msg kafka.Message) error {
+		return fmt.Errorf("no handler registered for topic %s", msg.Topic)
+	}
+}
+
+// record updates the health of the topic with the outcome of a message.
+func (r *Router) record(topic string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, ok := r.topics[topic]
+	if !ok {
+		status = &TopicHealth{}
+		r.topics[topic] = status
+	}
+
+	status.Processed++
+	status.LastMessageAt = time.Now()
+	status.LastError = ""
+
+	if err != nil {
+		status.Failed++
+		status.LastError = err.Error()
+		log.Printf("Error while handling message: topic=%s: %v", topic, err)
+	}
+}
+
+// stop marks the Router as stopped after its reader failed.
+func (r *Router) stop(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.running = false
+	r.lastErr = err
+}
@@ -0,0 +1,99 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// TestRouterTopics is a function to test the topic resolution and dispatching of Router.
+func TestRouterTopics(t *testing.T) {
+	var registered domain.RegisterUser
+	var patternTopic string
+
+	router := NewRouter(WithGroupId("test-group"), WithBrokers("localhost:9092"))
+	router.Handle(constants.TopicRegisterUser, Typed(func(_ context.Context, user domain.RegisterUser, _ kafka.Message) error {
+		registered = user
+		return nil
+	}))
+	router.HandlePattern(regexp.MustCompile(`^auth\.`), func(_ context.Context, msg kafka.Message) error {
+		patternTopic = msg.Topic
+		return nil
+	})
+
+	topics := router.matchTopics([]string{constants.TopicRegisterUser, constants.TopicResetPassword, "billing.invoice.v1"})
+	expected := []string{constants.TopicRegisterUser, constants.TopicResetPassword}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Errorf("matchTopics failed: expected %v but got %v", expected, topics)
+	}
+
+	msg := kafka.Message{Topic: constants.TopicRegisterUser, Value: []byte(`{"username": "john"}`)}
+	if err := router.handlerFor(msg.Topic)(context.Background(), msg); err != nil || registered.Username != "john" {
+		t.Errorf("handlerFor failed: expected typed handler but got %+v (err=%v)", registered, err)
+	}
+
+	msg = kafka.Message{Topic: constants.TopicResetPassword}
+	if err := router.handlerFor(msg.Topic)(context.Background(), msg); err != nil || patternTopic != msg.Topic {
+		t.Errorf("handlerFor failed: expected pattern handler but got %q (err=%v)", patternTopic, err)
+	}
+}
+
+// TestRouterHealth is a function to test Router.Health function.
+func TestRouterHealth(t *testing.T) {
+	router := NewRouter()
+	router.running = true
+
+	router.record(constants.TopicRegisterUser, nil)
+	if health := router.Health(); !health.Healthy || health.Topics[constants.TopicRegisterUser].Processed != 1 {
+		t.Errorf("Health failed: expected healthy router but got %+v", health)
+	}
+
+	router.record(constants.TopicResetPassword, errors.New("boom"))
+	if health := router.Health(); health.Healthy || health.Topics[constants.TopicResetPassword].Failed != 1 {
+		t.Errorf("Health failed: expected unhealthy router but got %+v", health)
+	}
+}
+
+// TestRouterClose is a function to test that Close stops a running Router and does not block before Run.
+func TestRouterClose(t *testing.T) {
+	router := NewRouter(WithGroupId("test-group"), WithBrokers("localhost:1"))
+	router.Handle(constants.TopicRegisterUser, func(context.Context, kafka.Message) error { return nil })
+
+	result := make(chan error, 1)
+	go func() { result <- router.Run(context.Background()) }()
+
+	for deadline := time.Now().Add(time.Second); !router.Health().Running && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := router.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Run failed: expected graceful shutdown but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run failed: expected to return after Close")
+	}
+	if router.Health().Running {
+		t.Error("Health failed: expected stopped router")
+	}
+
+	// A Router closed before running neither blocks Close nor starts consuming.
+	closed := NewRouter(WithGroupId("test-group"), WithBrokers("localhost:1"))
+	closed.Handle(constants.TopicRegisterUser, func(context.Context, kafka.Message) error { return nil })
+	if err := closed.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := closed.Run(context.Background()); err != nil || closed.Health().Running {
+		t.Errorf("Run failed: expected closed router to return but got %v", err)
+	}
+}
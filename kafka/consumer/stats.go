@@ -0,0 +1,253 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/segmentio/kafka-go"
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the processing of a KConsumer.
+type Stats struct {
+	Topic           string                 `json:"topic"`
+	GroupId         string                 `json:"group_id"`
+	Lag             int64                  `json:"lag"`
+	Processed       int64                  `json:"processed"`
+	Failed          int64                  `json:"failed"`
+	Rate            float64                `json:"rate"`
+	Rebalances      int64                  `json:"rebalances"`
+	LastRebalanceAt time.Time              `json:"last_rebalance_at"`
+	Errors          int64                  `json:"errors"`
+	StartedAt       time.Time              `json:"started_at"`
+	LastMessageAt   time.Time              `json:"last_message_at"`
+	HandlerStarted  *time.Time             `json:"handler_started,omitempty"`
+	Partitions      map[int]PartitionStats `json:"partitions"`
+	Reader          kafka.ReaderStats      `json:"-"`
+}
+
+// PartitionStats is the processing of a single partition.
+type PartitionStats struct {
+	Partition     int       `json:"partition"`
+	Offset        int64     `json:"offset"`
+	HighWaterMark int64     `json:"high_water_mark"`
+	Lag           int64     `json:"lag"`
+	Processed     int64     `json:"processed"`
+	Failed        int64     `json:"failed"`
+	Rate          float64   `json:"rate"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// StatsHook receives the periodic stats snapshots of a KConsumer, e.g. to export them as metrics.
+type StatsHook func(stats Stats)
+
+// HealthThresholds are the limits above which a KConsumer is reported unhealthy. Zero disables a check.
+type HealthThresholds struct {
+	// MaxLag is the maximum number of messages behind the high water mark.
+	MaxLag int64
+
+	// MaxIdle is the maximum time without processing a message while there is lag.
+	MaxIdle time.Duration
+
+	// MaxHandlerDuration is the maximum time a handler may run on a message or batch before it is reported stalled.
+	MaxHandlerDuration time.Duration
+}
+
+// ConsumerHealth is the health of a KConsumer with the reasons it is unhealthy.
+type ConsumerHealth struct {
+	Healthy bool     `json:"healthy"`
+	Reasons []string `json:"reasons,omitempty"`
+	Stats   Stats    `json:"stats"`
+}
+
+// DefaultHealthThresholds returns the thresholds from the KAFKA_CONSUMER_MAX_LAG, KAFKA_CONSUMER_MAX_IDLE_MS and
+// KAFKA_CONSUMER_MAX_HANDLER_MS config keys.
+func DefaultHealthThresholds() HealthThresholds {
+	return HealthThresholds{
+		MaxLag:             int64(config.GetInt("KAFKA_CONSUMER_MAX_LAG", 10000)),
+		MaxIdle:            time.Duration(config.GetInt("KAFKA_CONSUMER_MAX_IDLE_MS", 300000)) * time.Millisecond,
+		MaxHandlerDuration: time.Duration(config.GetInt("KAFKA_CONSUMER_MAX_HANDLER_MS", 300000)) * time.Millisecond,
+	}
+}
+
+// statsTracker accumulates the processing stats of a consumer. The counters of kafka.Reader.Stats are reset
+// on each call, so they are accumulated here as well.
+type statsTracker struct {
+	mu         sync.Mutex
+	startedAt  time.Time
+	partitions map[int]*PartitionStats
+
+	processed       int64
+	failed          int64
+	rebalances      int64
+	errors          int64
+	lastRebalanceAt time.Time
+	lastMessageAt   time.Time
+
+	// handlerStarted is when the handler started on the message or batch in flight, zero when idle.
+	handlerStarted time.Time
+
+	// Processed counters at the previous snapshot, to compute the rates.
+	snapshotAt        time.Time
+	snapshotProcessed map[int]int64
+}
+
+func newStatsTracker() *statsTracker {
+	now := time.Now()
+	return &statsTracker{
+		startedAt:         now,
+		snapshotAt:        now,
+		partitions:        make(map[int]*PartitionStats),
+		snapshotProcessed: make(map[int]int64),
+	}
+}
+
+// middleware returns a middleware recording the processed messages.
+func (s *statsTracker) middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg kafka.Message) error {
+			s.start(time.Now())
+			err := next(ctx, msg)
+			s.observe(msg, err, time.Now())
+			return err
+		}
+	}
+}
+
+// start records that the handler started on a message or batch.
+func (s *statsTracker) start(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlerStarted = now
+}
+
+// observe records a processed message, ending the handler in flight.
+func (s *statsTracker) observe(msg kafka.Message, err error, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlerStarted = time.Time{}
+
+	partition, ok := s.partitions[msg.Partition]
+	if !ok {
+		partition = &PartitionStats{Partition: msg.Partition}
+		s.partitions[msg.Partition] = partition
+	}
+
+	partition.Offset = msg.Offset
+	partition.Processed++
+	partition.LastMessageAt = now
+	if msg.HighWaterMark > 0 {
+		partition.HighWaterMark = msg.HighWaterMark
+		partition.Lag = msg.HighWaterMark - msg.Offset - 1
+	}
+
+	s.processed++
+	s.lastMessageAt = now
+	if err != nil {
+		partition.Failed++
+		s.failed++
+	}
+}
+
+// snapshot merges the reader stats into the accumulated stats and computes the rates since the previous snapshot,
+// which the next rates are computed from.
+func (s *statsTracker) snapshot(reader kafka.ReaderStats, now time.Time) Stats {
+	return s.collect(reader, now, true)
+}
+
+// peek merges the reader stats like snapshot, computing the rates since the previous snapshot without replacing it.
+func (s *statsTracker) peek(reader kafka.ReaderStats, now time.Time) Stats {
+	return s.collect(reader, now, false)
+}
+
+// collect merges the reader stats, whose counters are reset on every call of kafka.Reader.Stats, and builds the stats.
+// The rates are computed since the previous snapshot, replaced by this one when advance is set.
+func (s *statsTracker) collect(reader kafka.ReaderStats, now time.Time, advance bool) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors += reader.Errors
+	if reader.Rebalances > 0 {
+		s.rebalances += reader.Rebalances
+		s.lastRebalanceAt = now
+	}
+
+	elapsed := now.Sub(s.snapshotAt).Seconds()
+	stats := Stats{
+		Topic:           reader.Topic,
+		Processed:       s.processed,
+		Failed:          s.failed,
+		Rebalances:      s.rebalances,
+		LastRebalanceAt: s.lastRebalanceAt,
+		Errors:          s.errors,
+		StartedAt:       s.startedAt,
+		LastMessageAt:   s.lastMessageAt,
+		Partitions:      make(map[int]PartitionStats, len(s.partitions)),
+		Reader:          reader,
+	}
+
+	if !s.handlerStarted.IsZero() {
+		handlerStarted := s.handlerStarted
+		stats.HandlerStarted = &handlerStarted
+	}
+
+	var processedSince int64
+	for id, partition := range s.partitions {
+		partitionStats := *partition
+		delta := partition.Processed - s.snapshotProcessed[id]
+		processedSince += delta
+		if elapsed > 0 {
+			partitionStats.Rate = float64(delta) / elapsed
+		}
+
+		if advance {
+			s.snapshotProcessed[id] = partition.Processed
+		}
+		stats.Partitions[id] = partitionStats
+		stats.Lag += partition.Lag
+	}
+
+	// A reader without a consumer group reports the lag of its partition directly.
+	if reader.Lag > stats.Lag {
+		stats.Lag = reader.Lag
+	}
+
+	if elapsed > 0 {
+		stats.Rate = float64(processedSince) / elapsed
+	}
+	if advance {
+		s.snapshotAt = now
+	}
+
+	return stats
+}
+
+// evaluate returns the health of the stats against the thresholds.
+func evaluate(stats Stats, thresholds HealthThresholds, now time.Time) ConsumerHealth {
+	health := ConsumerHealth{Healthy: true, Stats: stats}
+
+	if thresholds.MaxLag > 0 && stats.Lag > thresholds.MaxLag {
+		health.Reasons = append(health.Reasons, fmt.Sprintf("lag %d exceeds %d", stats.Lag, thresholds.MaxLag))
+	}
+
+	if thresholds.MaxIdle > 0 && stats.Lag > 0 {
+		last := stats.LastMessageAt
+		if last.IsZero() {
+			last = stats.StartedAt
+		}
+		if idle := now.Sub(last); idle > thresholds.MaxIdle {
+			health.Reasons = append(health.Reasons, fmt.Sprintf("idle for %s with lag %d", idle.Round(time.Second), stats.Lag))
+		}
+	}
+
+	if thresholds.MaxHandlerDuration > 0 && stats.HandlerStarted != nil {
+		if running := now.Sub(*stats.HandlerStarted); running > thresholds.MaxHandlerDuration {
+			health.Reasons = append(health.Reasons, fmt.Sprintf("handler stalled for %s", running.Round(time.Second)))
+		}
+	}
+
+	health.Healthy = len(health.Reasons) == 0
+	return health
+}
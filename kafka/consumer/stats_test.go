@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// TestStatsTracker is a function to test the per-partition stats accumulated by statsTracker.
+func TestStatsTracker(t *testing.T) {
+	tracker := newStatsTracker()
+	start := tracker.snapshotAt
+
+	handler := Chain(func(_ context.Context, msg kafka.Message) error {
+		if msg.Offset == 11 {
+			return errors.New("boom")
+		}
+		return nil
+	}, tracker.middleware())
+
+	for _, msg := range []kafka.Message{
+		{Topic: "orders", Partition: 0, Offset: 10, HighWaterMark: 20},
+		{Topic: "orders", Partition: 0, Offset: 11, HighWaterMark: 20},
+		{Topic: "orders", Partition: 1, Offset: 4, HighWaterMark: 5},
+	} {
+		_ = handler(context.Background(), msg)
+	}
+
+	// Peeking, as health checks do, computes the rates without resetting them.
+	for i := 0; i < 2; i++ {
+		if stats := tracker.peek(kafka.ReaderStats{Topic: "orders"}, start.Add(time.Second)); stats.Rate != 3 {
+			t.Errorf("Stats failed: expected peeked rate %v but got %v", 3, stats.Rate)
+		}
+	}
+
+	stats := tracker.snapshot(kafka.ReaderStats{Topic: "orders", Rebalances: 1}, start.Add(2*time.Second))
+	if stats.Processed != 3 || stats.Failed != 1 {
+		t.Errorf("Stats failed: expected 3 processed and 1 failed but got %d and %d", stats.Processed, stats.Failed)
+	}
+	if stats.Lag != 8 {
+		t.Errorf("Stats failed: expected lag %d but got %d", 8, stats.Lag)
+	}
+	if stats.Rate != 1.5 {
+		t.Errorf("Stats failed: expected rate %v but got %v", 1.5, stats.Rate)
+	}
+	if partition := stats.Partitions[0]; partition.Offset != 11 || partition.Lag != 8 || partition.Rate != 1 {
+		t.Errorf("Stats failed: unexpected partition stats %+v", partition)
+	}
+	if stats.Rebalances != 1 || stats.LastRebalanceAt.IsZero() {
+		t.Errorf("Stats failed: expected 1 rebalance but got %d", stats.Rebalances)
+	}
+
+	// The reader counters are reset between calls, the tracker keeps accumulating them.
+	stats = tracker.snapshot(kafka.ReaderStats{Topic: "orders", Rebalances: 2}, start.Add(4*time.Second))
+	if stats.Rebalances != 3 {
+		t.Errorf("Stats failed: expected %d rebalances but got %d", 3, stats.Rebalances)
+	}
+	if stats.Rate != 0 {
+		t.Errorf("Stats failed: expected rate %v but got %v", 0, stats.Rate)
+	}
+}
+
+// TestEvaluate is a function to test evaluate function.
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	thresholds := HealthThresholds{MaxLag: 100, MaxIdle: time.Minute, MaxHandlerDuration: time.Minute}
+	handlerStarted, stalledStarted := now.Add(-time.Second), now.Add(-2*time.Minute)
+
+	tests := []struct {
+		name     string
+		stats    Stats
+		expected bool
+	}{
+		{"caught up", Stats{Lag: 0, LastMessageAt: now.Add(-time.Hour)}, true},
+		{"processing", Stats{Lag: 50, LastMessageAt: now.Add(-time.Second)}, true},
+		{"lagging", Stats{Lag: 500, LastMessageAt: now}, false},
+		{"stuck", Stats{Lag: 1, LastMessageAt: now.Add(-2 * time.Minute)}, false},
+		{"never processed", Stats{Lag: 1, StartedAt: now.Add(-2 * time.Minute)}, false},
+		{"handling", Stats{LastMessageAt: now, HandlerStarted: &handlerStarted}, true},
+		{"stalled", Stats{LastMessageAt: now, HandlerStarted: &stalledStarted}, false},
+	}
+
+	for _, test := range tests {
+		health := evaluate(test.stats, thresholds, now)
+		if health.Healthy != test.expected {
+			t.Errorf("evaluate failed for %s: expected %v but got %v (%v)", test.name, test.expected, health.Healthy, health.Reasons)
+		}
+	}
+}
+
+// TestStatsTrackerHandler is a function to test that statsTracker reports the handler in flight.
+func TestStatsTrackerHandler(t *testing.T) {
+	tracker := newStatsTracker()
+
+	var inFlight Stats
+	handler := Chain(func(context.Context, kafka.Message) error {
+		inFlight = tracker.peek(kafka.ReaderStats{}, time.Now())
+		return nil
+	}, tracker.middleware())
+	_ = handler(context.Background(), kafka.Message{Topic: "orders"})
+
+	if inFlight.HandlerStarted == nil {
+		t.Errorf("Stats failed: expected the handler in flight")
+	}
+	if stats := tracker.peek(kafka.ReaderStats{}, time.Now()); stats.HandlerStarted != nil {
+		t.Errorf("Stats failed: expected no handler in flight but got %v", stats.HandlerStarted)
+	}
+}
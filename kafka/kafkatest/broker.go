@@ -0,0 +1,187 @@
+package kafkatest
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Broker is an in-memory Kafka broker for tests. Topics are created on first use with the configured
+// number of partitions, and keyed messages are partitioned like the hash balancer of KProducer.
+// Members of a consumer group share the group offsets; rebalancing is not simulated.
+type Broker struct {
+	partitions int
+
+	mu        sync.Mutex
+	changed   chan struct{}
+	logs      map[string][][]kafka.Message
+	published []kafka.Message
+	positions map[groupPartition]int64
+	commits   map[groupPartition]int64
+}
+
+type groupPartition struct {
+	group     string
+	topic     string
+	partition int
+}
+
+// Option defines a function type for configuring the Broker.
+type Option func(*Broker)
+
+// WithPartitions sets the number of partitions of every topic.
+func WithPartitions(partitions int) Option {
+	return func(b *Broker) {
+		b.partitions = partitions
+	}
+}
+
+func NewBroker(options ...Option) *Broker {
+	broker := &Broker{
+		partitions: 1,
+		changed:    make(chan struct{}),
+		logs:       make(map[string][][]kafka.Message),
+		positions:  make(map[groupPartition]int64),
+		commits:    make(map[groupPartition]int64),
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(broker)
+	}
+
+	return broker
+}
+
+// WriteMessages appends the messages to their topic partitions, like kafka.Writer.WriteMessages.
+func (b *Broker) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	balancer := &kafka.Hash{}
+	for _, msg := range msgs {
+		partitions := b.topic(msg.Topic)
+
+		ids := make([]int, len(partitions))
+		for i := range ids {
+			ids[i] = i
+		}
+
+		msg.Partition = balancer.Balance(msg, ids...)
+		msg.Offset = int64(len(partitions[msg.Partition]))
+		if msg.Time.IsZero() {
+			msg.Time = time.Now()
+		}
+
+		partitions[msg.Partition] = append(partitions[msg.Partition], msg)
+		b.published = append(b.published, msg)
+	}
+
+	// Wake up the waiting subscribers.
+	close(b.changed)
+	b.changed = make(chan struct{})
+
+	return nil
+}
+
+// FetchMessage returns the next message of the topic not yet fetched by the group, waiting until one is available.
+func (b *Broker) FetchMessage(ctx context.Context, group, topic string) (kafka.Message, error) {
+	for {
+		b.mu.Lock()
+		msg, ok := b.next(group, topic)
+		changed := b.changed
+		b.mu.Unlock()
+
+		if ok {
+			return msg, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// CommitMessages commits the offsets of the messages for the group.
+func (b *Broker) CommitMessages(_ context.Context, group string, msgs ...kafka.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, msg := range msgs {
+		key := groupPartition{group: group, topic: msg.Topic, partition: msg.Partition}
+		if msg.Offset+1 > b.commits[key] {
+			b.commits[key] = msg.Offset + 1
+		}
+	}
+	return nil
+}
+
+// CommittedOffset returns the next offset the group will consume from the partition.
+func (b *Broker) CommittedOffset(group, topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.commits[groupPartition{group: group, topic: topic, partition: partition}]
+}
+
+// Messages returns the messages of the topic in publish order.
+func (b *Broker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs := make([]kafka.Message, 0)
+	for _, msg := range b.published {
+		if msg.Topic == topic {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// Topics returns the sorted names of the topics that received messages.
+func (b *Broker) Topics() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topics := make([]string, 0, len(b.logs))
+	for topic := range b.logs {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// topic returns the partitions of the topic, creating it if needed. The caller must hold the lock.
+func (b *Broker) topic(name string) [][]kafka.Message {
+	partitions, ok := b.logs[name]
+	if !ok {
+		partitions = make([][]kafka.Message, b.partitions)
+		b.logs[name] = partitions
+	}
+	return partitions
+}
+
+// next returns the next message to deliver to the group and advances its position.
+// A group starts from its committed offsets. The caller must hold the lock.
+func (b *Broker) next(group, topic string) (kafka.Message, bool) {
+	for partition, log := range b.topic(topic) {
+		key := groupPartition{group: group, topic: topic, partition: partition}
+
+		position, ok := b.positions[key]
+		if !ok {
+			position = b.commits[key]
+		}
+
+		if position < int64(len(log)) {
+			b.positions[key] = position + 1
+			msg := log[position]
+			msg.HighWaterMark = int64(len(log))
+			return msg, true
+		}
+	}
+	return kafka.Message{}, false
+}
@@ -0,0 +1,87 @@
+package kafkatest
+
+import (
+	"context"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/consumer"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// TestBroker is a function to test publishing and consuming through the Broker.
+func TestBroker(t *testing.T) {
+	broker := NewBroker(WithPartitions(3))
+	publisher := broker.Publisher()
+
+	users := []domain.RegisterUser{{Username: "alice"}, {Username: "bob"}, {Username: "carol"}}
+	for _, user := range users {
+		if err := publisher.Publish(context.Background(), user.Username, constants.TopicRegisterUser, user); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	broker.ExpectPublished(t, constants.TopicRegisterUser, All(KeyEquals("bob"), JSONEquals(users[1])))
+	broker.ExpectNotPublished(t, constants.TopicResetPassword, Any())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	received := make(chan domain.RegisterUser, len(users))
+	var subscriber consumer.Subscriber = broker.Subscriber(constants.TopicRegisterUser, "test-group")
+	go subscriber.ConsumeWith(ctx, consumer.Typed(func(_ context.Context, user domain.RegisterUser, _ kafka.Message) error {
+		received <- user
+		return nil
+	}))
+
+	for range users {
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatal("ConsumeWith failed: timed out waiting for messages")
+		}
+	}
+
+	// Every partition is committed up to its last message.
+	for _, msg := range broker.Messages(constants.TopicRegisterUser) {
+		deadline := time.Now().Add(time.Second)
+		for broker.CommittedOffset("test-group", msg.Topic, msg.Partition) <= msg.Offset && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+
+		if committed := broker.CommittedOffset("test-group", msg.Topic, msg.Partition); committed <= msg.Offset {
+			t.Errorf("CommittedOffset failed: expected offset > %d on partition %d but got %d", msg.Offset, msg.Partition, committed)
+		}
+	}
+}
+
+// TestBrokerConsumerGroups is a function to test that consumer groups track their offsets independently.
+func TestBrokerConsumerGroups(t *testing.T) {
+	broker := NewBroker()
+	broker.Publisher().Produce("key", constants.TopicResetPassword, domain.ResetPassword{Email: "john@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	first := broker.Subscriber(constants.TopicResetPassword, "group-a")
+	msg, err := first.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("FetchMessage failed: %v", err)
+	}
+
+	if err = first.CommitMessages(ctx, msg); err != nil || broker.CommittedOffset("group-a", msg.Topic, msg.Partition) != 1 {
+		t.Errorf("CommitMessages failed: expected committed offset 1 (err=%v)", err)
+	}
+
+	if _, err = broker.Subscriber(constants.TopicResetPassword, "group-b").FetchMessage(ctx); err != nil {
+		t.Errorf("FetchMessage failed: expected group-b to receive the message but got %v", err)
+	}
+
+	// group-a has nothing left to consume.
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+	if _, err = first.FetchMessage(short); err == nil {
+		t.Error("FetchMessage failed: expected no message for group-a")
+	}
+}
@@ -0,0 +1,112 @@
+package kafkatest
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/consumer"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/serde"
+	"github.com/segmentio/kafka-go"
+	"log"
+)
+
+var (
+	_ producer.Publisher  = (*Publisher)(nil)
+	_ consumer.Subscriber = (*Subscriber)(nil)
+)
+
+// Publisher is an in-memory producer.Publisher writing to a Broker.
+type Publisher struct {
+	broker *Broker
+
+	// Serializer encodes published values. Defaults to JSON when nil.
+	Serializer serde.Serializer
+}
+
+// Publisher returns a producer.Publisher writing to the broker.
+func (b *Broker) Publisher() *Publisher {
+	return &Publisher{broker: b}
+}
+
+// Produce publishes the value, logging errors like KProducer.
+func (p *Publisher) Produce(key, topic string, data interface{}, options ...producer.ProduceOption) {
+	if err := p.Publish(context.Background(), key, topic, data, options...); err != nil {
+		log.Printf("Error producing message: %v", err)
+	}
+}
+
+// Publish serializes the value and writes it to the broker with an event-id header, like KProducer.
+// Delayed messages are written immediately with their Time set to the delivery time, so that tests can assert on it.
+func (p *Publisher) Publish(ctx context.Context, key, topic string, data interface{}, options ...producer.ProduceOption) error {
+	serializer := p.Serializer
+	if serializer == nil {
+		serializer = serde.JSON{}
+	}
+
+	value, err := serializer.Serialize(topic, data)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	return p.broker.WriteMessages(ctx, kafka.Message{
+		Time:  producer.NewProduceOptions(options...).DeliverAt,
+		Topic: topic,
+		Key:   []byte(key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: constants.KafkaHeaderEventId, Value: []byte(uuid.NewString())},
+		},
+	})
+}
+
+// Subscriber is an in-memory consumer.Subscriber reading a topic of a Broker as a member of a consumer group.
+type Subscriber struct {
+	broker *Broker
+	topic  string
+	group  string
+}
+
+// Subscriber returns a consumer.Subscriber reading the topic as a member of the group.
+func (b *Broker) Subscriber(topic, group string) *Subscriber {
+	return &Subscriber{broker: b, topic: topic, group: group}
+}
+
+// FetchMessage returns the next message without committing it.
+func (s *Subscriber) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return s.broker.FetchMessage(ctx, s.group, s.topic)
+}
+
+// CommitMessages commits the offsets of the messages for the group.
+func (s *Subscriber) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return s.broker.CommitMessages(ctx, s.group, msgs...)
+}
+
+// Consume handles the messages until the context is done.
+func (s *Subscriber) Consume(ctx context.Context, handler func(msg kafka.Message)) {
+	s.ConsumeWith(ctx, func(_ context.Context, msg kafka.Message) error {
+		handler(msg)
+		return nil
+	})
+}
+
+// ConsumeWith handles the messages through the middlewares until the context is done.
+// Like KConsumer, every message is committed after the handler returns and panics are recovered.
+func (s *Subscriber) ConsumeWith(ctx context.Context, handler consumer.HandlerFunc, middlewares ...consumer.Middleware) {
+	handler = consumer.Chain(handler, append([]consumer.Middleware{consumer.Recovery()}, middlewares...)...)
+
+	for {
+		msg, err := s.FetchMessage(ctx)
+		if err != nil {
+			return
+		}
+
+		if err = handler(ctx, msg); err != nil {
+			log.Printf("Error while handling message: topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+		}
+
+		_ = s.CommitMessages(ctx, msg)
+	}
+}
@@ -0,0 +1,90 @@
+package kafkatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"reflect"
+	"testing"
+)
+
+// Matcher reports whether a message matches an expectation.
+type Matcher func(msg kafka.Message) bool
+
+// Any matches every message.
+func Any() Matcher {
+	return func(kafka.Message) bool {
+		return true
+	}
+}
+
+// KeyEquals matches messages with the given key.
+func KeyEquals(key string) Matcher {
+	return func(msg kafka.Message) bool {
+		return string(msg.Key) == key
+	}
+}
+
+// HeaderEquals matches messages carrying the header with the given value.
+func HeaderEquals(key, value string) Matcher {
+	return func(msg kafka.Message) bool {
+		for _, header := range msg.Headers {
+			if header.Key == key && bytes.Equal(header.Value, []byte(value)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// JSONEquals matches messages whose JSON value decodes to the expected value.
+func JSONEquals[T any](expected T) Matcher {
+	return func(msg kafka.Message) bool {
+		var actual T
+		if err := json.Unmarshal(msg.Value, &actual); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(actual, expected)
+	}
+}
+
+// All matches messages matching every matcher.
+func All(matchers ...Matcher) Matcher {
+	return func(msg kafka.Message) bool {
+		for _, matcher := range matchers {
+			if !matcher(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ExpectPublished fails the test unless a message matching the matcher was published to the topic.
+// Returns the first matching message.
+func (b *Broker) ExpectPublished(t testing.TB, topic string, matcher Matcher) kafka.Message {
+	t.Helper()
+
+	msgs := b.Messages(topic)
+	for _, msg := range msgs {
+		if matcher(msg) {
+			return msg
+		}
+	}
+
+	t.Errorf("expected a matching message on topic %s, got %d non-matching messages", topic, len(msgs))
+	return kafka.Message{}
+}
+
+// ExpectNotPublished fails the test if a message matching the matcher was published to the topic.
+func (b *Broker) ExpectNotPublished(t testing.TB, topic string, matcher Matcher) {
+	t.Helper()
+
+	for _, msg := range b.Messages(topic) {
+		if matcher(msg) {
+			t.Errorf("expected no matching message on topic %s, got offset %d on partition %d",
+				topic, msg.Offset, msg.Partition)
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package producer
+
+import (
+	"crypto/tls"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"time"
+)
+
+// Option defines a function type for configuring the KProducer writer.
+type Option func(*kafka.Writer)
+
+// WithBrokers sets the brokers of the writer.
+func WithBrokers(brokers ...string) Option {
+	return func(w *kafka.Writer) {
+		w.Addr = kafka.TCP(brokers...)
+	}
+}
+
+// WithBalancer sets how messages are distributed across partitions.
+func WithBalancer(balancer kafka.Balancer) Option {
+	return func(w *kafka.Writer) {
+		w.Balancer = balancer
+	}
+}
+
+// WithHashBalancer routes messages with the same key to the same partition, so keyed events keep their order.
+func WithHashBalancer() Option {
+	return WithBalancer(&kafka.Hash{})
+}
+
+// WithCompression sets the compression codec of the produced batches.
+func WithCompression(compression kafka.Compression) Option {
+	return func(w *kafka.Writer) {
+		w.Compression = compression
+	}
+}
+
+// WithRequiredAcks sets the number of acknowledgements required from the brokers.
+func WithRequiredAcks(acks kafka.RequiredAcks) Option {
+	return func(w *kafka.Writer) {
+		w.RequiredAcks = acks
+	}
+}
+
+// WithBatchSize sets the maximum number of messages buffered before a batch is sent.
+func WithBatchSize(size int) Option {
+	return func(w *kafka.Writer) {
+		w.BatchSize = size
+	}
+}
+
+// WithBatchTimeout sets how long an incomplete batch is buffered before it is sent.
+func WithBatchTimeout(timeout time.Duration) Option {
+	return func(w *kafka.Writer) {
+		w.BatchTimeout = timeout
+	}
+}
+
+// WithAsync makes writes return immediately. Delivery errors are then only reported through the writer Completion.
+func WithAsync(async bool) Option {
+	return func(w *kafka.Writer) {
+		w.Async = async
+	}
+}
+
+// WithSASL sets the SASL mechanism used to authenticate against the brokers.
+func WithSASL(mechanism sasl.Mechanism) Option {
+	return func(w *kafka.Writer) {
+		transport(w).SASL = mechanism
+	}
+}
+
+// WithTLS enables TLS with the given configuration.
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(w *kafka.Writer) {
+		transport(w).TLS = tlsConfig
+	}
+}
+
+// transport returns the kafka.Transport of the writer, creating it if needed.
+func transport(w *kafka.Writer) *kafka.Transport {
+	if t, ok := w.Transport.(*kafka.Transport); ok {
+		return t
+	}
+
+	t := &kafka.Transport{}
+	w.Transport = t
+	return t
+}
+
+// ProduceOptions are the per-message settings of Produce and Publish.
+type ProduceOptions struct {
+	// DeliverAt is the time the message is delivered to its topic. Zero or past times deliver immediately.
+	DeliverAt time.Time
+}
+
+// ProduceOption defines a function type for configuring a produced message.
+type ProduceOption func(*ProduceOptions)
+
+// NewProduceOptions applies the options, so that other Publisher implementations can honor them.
+func NewProduceOptions(options ...ProduceOption) ProduceOptions {
+	var produceOptions ProduceOptions
+
+	// Apply custom options
+	for _, option := range options {
+		option(&produceOptions)
+	}
+
+	return produceOptions
+}
+
+// DeliverAt delays the delivery of the message until the given time.
+func DeliverAt(at time.Time) ProduceOption {
+	return func(o *ProduceOptions) {
+		o.DeliverAt = at
+	}
+}
+
+// DeliverAfter delays the delivery of the message by the given duration.
+func DeliverAfter(delay time.Duration) ProduceOption {
+	return func(o *ProduceOptions) {
+		o.DeliverAt = time.Now().Add(delay)
+	}
+}
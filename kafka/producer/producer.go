@@ -2,55 +2,166 @@ package producer
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
 	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/serde"
 	"github.com/segmentio/kafka-go"
 	"log"
-	"strings"
 	"time"
 )
 
+// Publisher publishes values to Kafka topics. KProducer and kafkatest.Publisher satisfy it.
+type Publisher interface {
+	Produce(key, topic string, data interface{}, options ...ProduceOption)
+	Publish(ctx context.Context, key, topic string, data interface{}, options ...ProduceOption) error
+}
+
+// Scheduler stores messages to be written to their topic at a later time. scheduler.Scheduler satisfies it.
+type Scheduler interface {
+	Schedule(ctx context.Context, at time.Time, msg kafka.Message) error
+}
+
 type KProducer struct {
 	Writer *kafka.Writer
+
+	// Serializer encodes produced values. Defaults to JSON when nil.
+	Serializer serde.Serializer
+
+	// Scheduler stores the messages produced with DeliverAt or DeliverAfter. Delayed messages fail without it.
+	Scheduler Scheduler
 }
 
-func NewKProducer() *KProducer {
-	return &KProducer{
-		Writer: &kafka.Writer{
-			Addr:     kafka.TCP(strings.Split(config.GetString("KAFKA_BROKERS", "localhost:9092"), ",")...),
-			Balancer: &kafka.LeastBytes{},
-		},
+// NewKProducer creates a producer configured from the KAFKA_* config keys and the given options.
+// It panics if the configured SASL or TLS settings are invalid.
+func NewKProducer(options ...Option) *KProducer {
+	clientConfig := swekafka.LoadClientConfig()
+
+	transport, err := clientConfig.Transport()
+	if err != nil {
+		panic(err)
+	}
+
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(clientConfig.Brokers...),
+		Balancer:     balancer(config.GetString("KAFKA_PRODUCER_BALANCER", "least_bytes")),
+		BatchSize:    config.GetInt("KAFKA_PRODUCER_BATCH_SIZE", 100),
+		BatchTimeout: time.Duration(config.GetInt("KAFKA_PRODUCER_BATCH_TIMEOUT_MS", 1000)) * time.Millisecond,
+		Async:        config.GetBool("KAFKA_PRODUCER_ASYNC", false),
+		Transport:    transport,
+	}
+
+	if err = writer.Compression.UnmarshalText([]byte(config.GetString("KAFKA_PRODUCER_COMPRESSION", "none"))); err != nil {
+		panic(err)
 	}
+
+	if err = writer.RequiredAcks.UnmarshalText([]byte(config.GetString("KAFKA_PRODUCER_REQUIRED_ACKS", "none"))); err != nil {
+		panic(err)
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(writer)
+	}
+
+	return &KProducer{Writer: writer}
 }
 
-// Produce is a function that sends a message to the Kafka broker.
-func (k *KProducer) Produce(key, topic string, data interface{}) {
-	msgBytes, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("Error when marshal message: %v", err)
-		return
+// balancer returns the partition balancer for the given name, defaulting to least bytes.
+func balancer(name string) kafka.Balancer {
+	switch name {
+	case "hash":
+		return &kafka.Hash{}
+	case "murmur2":
+		return kafka.Murmur2Balancer{}
+	case "round_robin":
+		return &kafka.RoundRobin{}
+	default:
+		return &kafka.LeastBytes{}
 	}
+}
 
+// Produce is a function that sends a message to the Kafka broker.
+// Errors are logged; use Publish to handle them.
+func (k *KProducer) Produce(key, topic string, data interface{}, options ...ProduceOption) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if err := k.Publish(ctx, key, topic, data, options...); err != nil {
+		log.Printf("Error producing message: %v", err)
+	}
+}
+
+// Publish sends a message to the Kafka broker, retrying transient errors until the context is done.
+// Messages delivered in the future are handed to the Scheduler instead.
+func (k *KProducer) Publish(ctx context.Context, key, topic string, data interface{}, options ...ProduceOption) error {
+	msgBytes, err := k.serializer().Serialize(topic, data)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	// The event ID stays the same across retries so that consumers can detect duplicates.
+	eventId := uuid.NewString()
+
+	if produceOptions := NewProduceOptions(options...); produceOptions.DeliverAt.After(time.Now()) {
+		if k.Scheduler == nil {
+			return fmt.Errorf("failed to schedule message: no scheduler configured")
+		}
+
+		err = k.Scheduler.Schedule(ctx, produceOptions.DeliverAt, kafka.Message{
+			Topic: topic,
+			Key:   []byte(key),
+			Value: msgBytes,
+			Headers: []kafka.Header{
+				{Key: constants.KafkaHeaderEventId, Value: []byte(eventId)},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to schedule message: %w", err)
+		}
+
+		log.Printf("Message scheduled: topic=%s key=%s deliverAt=%s", topic, key, produceOptions.DeliverAt.Format(time.RFC3339))
+		return nil
+	}
+
 	// Retry 3 times before giving up. This is to handle transient errors.
 	for retries := 0; retries < 3; retries++ {
-		log.Printf("Attempting to produce message: topic=%s key=%s value=%s (attempt %d)",
-			topic, key, string(msgBytes), retries+1)
+		log.Printf("Attempting to produce message: topic=%s key=%s size=%d (attempt %d)",
+			topic, key, len(msgBytes), retries+1)
 
 		err = k.Writer.WriteMessages(ctx, kafka.Message{
 			Topic: topic,
 			Key:   []byte(key),
 			Value: msgBytes,
+			Headers: []kafka.Header{
+				{Key: constants.KafkaHeaderEventId, Value: []byte(eventId)},
+			},
 		})
 
 		if err == nil {
 			log.Println("Message produced successfully")
-			return
+			return nil
 		}
 
 		log.Printf("Error producing message: %v", err)
-		time.Sleep(2 * time.Second) // Wait before retrying
+
+		// Wait before retrying
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to produce message: %w", err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	return fmt.Errorf("failed to produce message after retries: %w", err)
+}
+
+// serializer returns the configured serializer, defaulting to JSON.
+func (k *KProducer) serializer() serde.Serializer {
+	if k.Serializer == nil {
+		return serde.JSON{}
 	}
+	return k.Serializer
 }
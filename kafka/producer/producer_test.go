@@ -0,0 +1,101 @@
+package producer
+
+import (
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+// setConfig sets the config keys for the test, unsetting them afterward.
+func setConfig(t *testing.T, settings map[string]interface{}) {
+	t.Helper()
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+	t.Cleanup(func() {
+		for key := range settings {
+			viper.Set(key, nil)
+		}
+	})
+}
+
+// TestNewKProducerConfig is a function to test the compression and required acks parsed from the config keys.
+func TestNewKProducerConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		compression  string
+		requiredAcks string
+		expectedComp kafka.Compression
+		expectedAcks kafka.RequiredAcks
+		expectPanic  bool
+	}{
+		{name: "defaults", expectedComp: kafka.Compression(0), expectedAcks: kafka.RequireNone},
+		{name: "gzip all", compression: "gzip", requiredAcks: "all", expectedComp: kafka.Gzip, expectedAcks: kafka.RequireAll},
+		{name: "snappy one", compression: "snappy", requiredAcks: "one", expectedComp: kafka.Snappy, expectedAcks: kafka.RequireOne},
+		{name: "lz4", compression: "lz4", requiredAcks: "none", expectedComp: kafka.Lz4, expectedAcks: kafka.RequireNone},
+		{name: "zstd numeric acks", compression: "zstd", requiredAcks: "-1", expectedComp: kafka.Zstd, expectedAcks: kafka.RequireAll},
+		{name: "invalid compression", compression: "brotli", expectPanic: true},
+		{name: "invalid acks", requiredAcks: "2", expectPanic: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := map[string]interface{}{}
+			if test.compression != "" {
+				settings["KAFKA_PRODUCER_COMPRESSION"] = test.compression
+			}
+			if test.requiredAcks != "" {
+				settings["KAFKA_PRODUCER_REQUIRED_ACKS"] = test.requiredAcks
+			}
+			setConfig(t, settings)
+
+			defer func() {
+				if recovered := recover(); (recovered != nil) != test.expectPanic {
+					t.Errorf("NewKProducer failed: expected panic %v but got %v", test.expectPanic, recovered)
+				}
+			}()
+
+			writer := NewKProducer().Writer
+			if writer.Compression != test.expectedComp || writer.RequiredAcks != test.expectedAcks {
+				t.Errorf("NewKProducer failed: expected %v, %v but got %v, %v",
+					test.expectedComp, test.expectedAcks, writer.Compression, writer.RequiredAcks)
+			}
+		})
+	}
+}
+
+// TestBalancer is a function to test balancer function.
+func TestBalancer(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected kafka.Balancer
+	}{
+		{"hash", &kafka.Hash{}},
+		{"murmur2", kafka.Murmur2Balancer{}},
+		{"round_robin", &kafka.RoundRobin{}},
+		{"least_bytes", &kafka.LeastBytes{}},
+		{"", &kafka.LeastBytes{}},
+		{"unknown", &kafka.LeastBytes{}},
+	}
+
+	for _, test := range tests {
+		if actual := balancer(test.name); fmt.Sprintf("%T", actual) != fmt.Sprintf("%T", test.expected) {
+			t.Errorf("balancer failed for %q: expected %T but got %T", test.name, test.expected, actual)
+		}
+	}
+}
+
+// TestNewKProducerBalancer is a function to test the balancer and batching parsed from the config keys.
+func TestNewKProducerBalancer(t *testing.T) {
+	setConfig(t, map[string]interface{}{
+		"KAFKA_PRODUCER_BALANCER":   "murmur2",
+		"KAFKA_PRODUCER_BATCH_SIZE": 10,
+	})
+
+	writer := NewKProducer().Writer
+	if _, ok := writer.Balancer.(kafka.Murmur2Balancer); !ok || writer.BatchSize != 10 {
+		t.Errorf("NewKProducer failed: expected murmur2 balancer and batch size %d but got %T, %d",
+			10, writer.Balancer, writer.BatchSize)
+	}
+}
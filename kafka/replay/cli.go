@@ -0,0 +1,150 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/consumer"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("log", func(_ context.Context, msg kafka.Message) error {
+		log.Printf("topic=%s partition=%d offset=%d time=%s key=%s size=%d",
+			msg.Topic, msg.Partition, msg.Offset, msg.Time.Format(time.RFC3339), string(msg.Key), len(msg.Value))
+		return nil
+	})
+}
+
+// Main runs the replay command with the command line arguments until interrupted, exiting on failure.
+// Services register their handlers with Register and call it from their own main:
+//
+//	func main() {
+//		replay.Register("reindex-users", usersIndexer.Handle)
+//		replay.Main()
+//	}
+func Main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := RunCLI(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	} else if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+}
+
+// RunCLI parses the flags of the replay command from the arguments, without the program name, runs the replay
+// and writes its result as JSON to the output. The brokers are configured by the KAFKA_* config keys.
+func RunCLI(ctx context.Context, args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("kafka-replay", flag.ContinueOnError)
+	var (
+		topic       = flags.String("topic", "", "topic to replay (required)")
+		from        = flags.String("from", "", "replay messages written at or after this RFC3339 time")
+		to          = flags.String("to", "", "replay messages written before this RFC3339 time")
+		startOffset = flags.Int64("start-offset", -1, "first offset to replay on each partition")
+		endOffset   = flags.Int64("end-offset", -1, "offset to stop before on each partition")
+		partitions  = flags.String("partitions", "", "comma separated partitions to replay, all by default")
+		tables      = flags.String("tables", "", "comma separated CDC tables (source.table) to replay")
+		keys        = flags.String("keys", "", "comma separated message keys to replay")
+		handlerName = flags.String("handler", "log", fmt.Sprintf("registered handler to replay through %v", Handlers()))
+		toTopic     = flags.String("to-topic", "", "topic to copy the messages into instead of using a handler")
+		dryRun      = flags.Bool("dry-run", false, "count the matching messages without replaying them")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *topic == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	options := []Option{WithOffsetRange(*startOffset, *endOffset)}
+
+	fromTime, err := parseTime(*from)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	toTime, err := parseTime(*to)
+	if err != nil {
+		return fmt.Errorf("invalid -to: %w", err)
+	}
+	options = append(options, WithTimeRange(fromTime, toTime))
+
+	if *partitions != "" {
+		var ids []int
+		for _, value := range splitList(*partitions) {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid -partitions: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		options = append(options, WithPartitions(ids...))
+	}
+
+	if *tables != "" {
+		options = append(options, WithTables(splitList(*tables)...))
+	}
+	if *keys != "" {
+		options = append(options, WithKeys(splitList(*keys)...))
+	}
+	if *dryRun {
+		options = append(options, WithDryRun())
+	}
+
+	var handler consumer.HandlerFunc
+	if *toTopic == "" {
+		var ok bool
+		if handler, ok = Lookup(*handlerName); !ok {
+			return fmt.Errorf("unknown handler %q, registered handlers: %v", *handlerName, Handlers())
+		}
+	}
+
+	config.Init()
+
+	if *toTopic != "" {
+		kProducer := producer.NewKProducer()
+		defer kProducer.Writer.Close()
+		handler = ToTopic(kProducer.Writer, *toTopic)
+	}
+
+	result, err := NewReplayer(*topic, options...).Run(ctx, handler)
+	if result != nil {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		_, _ = fmt.Fprintln(output, string(data))
+	}
+	return err
+}
+
+// parseTime parses an RFC3339 time, returning the zero time for an empty value.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// splitList splits a comma separated list, trimming spaces.
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,283 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/cdc"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/consumer"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Filter selects the messages to replay.
+type Filter func(msg kafka.Message) bool
+
+// Range is the half-open offset range [Start, End) replayed on a partition.
+type Range struct {
+	Partition int   `json:"partition"`
+	Start     int64 `json:"start"`
+	End       int64 `json:"end"`
+}
+
+// Result summarizes a replay.
+type Result struct {
+	Ranges   []Range `json:"ranges"`
+	Scanned  int64   `json:"scanned"`
+	Matched  int64   `json:"matched"`
+	Replayed int64   `json:"replayed"`
+	Failed   int64   `json:"failed"`
+	DryRun   bool    `json:"dry_run"`
+}
+
+// source resolves the offset ranges of a replay and reads them.
+type source interface {
+	Ranges(ctx context.Context, replayer *Replayer) ([]Range, error)
+	Read(ctx context.Context, topic string, r Range, fn func(msg kafka.Message) error) error
+}
+
+// Replayer re-feeds the messages of a topic within a time window or an offset range, regardless of the
+// committed offsets of any consumer group.
+type Replayer struct {
+	topic       string
+	from        time.Time
+	to          time.Time
+	startOffset int64
+	endOffset   int64
+	partitions  []int
+	filters     []Filter
+	dryRun      bool
+	source      source
+}
+
+// Option defines a function type for configuring the Replayer.
+type Option func(*Replayer)
+
+// WithTimeRange replays the messages written from the from time (inclusive) until the to time (exclusive).
+// A zero time leaves that side of the window open.
+func WithTimeRange(from, to time.Time) Option {
+	return func(r *Replayer) {
+		r.from = from
+		r.to = to
+	}
+}
+
+// WithOffsetRange replays the offsets from start (inclusive) to end (exclusive) on every selected partition.
+// A negative offset leaves that side of the range open.
+func WithOffsetRange(start, end int64) Option {
+	return func(r *Replayer) {
+		r.startOffset = start
+		r.endOffset = end
+	}
+}
+
+// WithPartitions restricts the replay to the given partitions. All partitions are replayed by default.
+func WithPartitions(partitions ...int) Option {
+	return func(r *Replayer) {
+		r.partitions = partitions
+	}
+}
+
+// WithFilter only replays the messages accepted by the filter. Filters are combined with AND.
+func WithFilter(filter Filter) Option {
+	return func(r *Replayer) {
+		r.filters = append(r.filters, filter)
+	}
+}
+
+// WithTables only replays the CDC events of the given tables (CdcSource.Table).
+func WithTables(tables ...string) Option {
+	return WithFilter(TableFilter(tables...))
+}
+
+// WithKeys only replays the messages with the given keys.
+func WithKeys(keys ...string) Option {
+	return WithFilter(KeyFilter(keys...))
+}
+
+// WithDryRun counts the messages that would be replayed without passing them to the handler.
+func WithDryRun() Option {
+	return func(r *Replayer) {
+		r.dryRun = true
+	}
+}
+
+// NewReplayer creates a replayer of the topic reading from the brokers configured by the KAFKA_* config keys.
+// It panics if the configured SASL or TLS settings are invalid.
+func NewReplayer(topic string, options ...Option) *Replayer {
+	replayer := &Replayer{
+		topic:       topic,
+		startOffset: -1,
+		endOffset:   -1,
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(replayer)
+	}
+
+	if replayer.source == nil {
+		replayer.source = newKafkaSource()
+	}
+
+	return replayer
+}
+
+// Run passes the selected messages to the handler, partitions in parallel and each partition in order.
+// A failing handler is logged and counted without stopping the replay.
+func (r *Replayer) Run(ctx context.Context, handler consumer.HandlerFunc) (*Result, error) {
+	ranges, err := r.source.Ranges(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve replay ranges of topic %s: %w", r.topic, err)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Partition < ranges[j].Partition
+	})
+
+	result := &Result{Ranges: ranges, DryRun: r.dryRun}
+	log.Printf("Replaying topic %s: ranges=%v dryRun=%t", r.topic, ranges, r.dryRun)
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(ranges))
+	)
+
+	handler = consumer.Chain(handler, consumer.Recovery())
+	for i, rng := range ranges {
+		if rng.Start >= rng.End {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, rng Range) {
+			defer wg.Done()
+
+			errs[i] = r.source.Read(ctx, r.topic, rng, func(msg kafka.Message) error {
+				matched := r.matches(msg)
+
+				var handleErr error
+				if matched && !r.dryRun {
+					if handleErr = handler(ctx, msg); handleErr != nil {
+						log.Printf("Error while replaying message: topic=%s partition=%d offset=%d: %v",
+							msg.Topic, msg.Partition, msg.Offset, handleErr)
+					}
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				result.Scanned++
+				if matched {
+					result.Matched++
+					switch {
+					case handleErr != nil:
+						result.Failed++
+					case !r.dryRun:
+						result.Replayed++
+					}
+				}
+				return nil
+			})
+		}(i, rng)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return result, fmt.Errorf("failed to replay partition %d: %w", ranges[i].Partition, err)
+		}
+	}
+
+	log.Printf("Replayed topic %s: scanned=%d matched=%d replayed=%d failed=%d",
+		r.topic, result.Scanned, result.Matched, result.Replayed, result.Failed)
+	return result, nil
+}
+
+// matches reports whether the message passes every filter.
+func (r *Replayer) matches(msg kafka.Message) bool {
+	for _, filter := range r.filters {
+		if !filter(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// TableFilter accepts the CDC events of the given tables. Tombstones and non CDC messages are rejected.
+func TableFilter(tables ...string) Filter {
+	set := toSet(tables)
+	return func(msg kafka.Message) bool {
+		event, err := cdc.Decode(msg.Value)
+		return err == nil && set[event.Source.Table]
+	}
+}
+
+// KeyFilter accepts the messages with the given keys.
+func KeyFilter(keys ...string) Filter {
+	set := toSet(keys)
+	return func(msg kafka.Message) bool {
+		return set[string(msg.Key)]
+	}
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// Writer writes messages to their topic. kafka.Writer satisfies it.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// ToTopic returns a handler copying the messages with their key and headers into another topic.
+func ToTopic(writer Writer, topic string) consumer.HandlerFunc {
+	return func(ctx context.Context, msg kafka.Message) error {
+		return writer.WriteMessages(ctx, kafka.Message{
+			Topic:   topic,
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: msg.Headers,
+		})
+	}
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]consumer.HandlerFunc)
+)
+
+// Register makes the handler available to the replay command under the given name.
+func Register(name string, handler consumer.HandlerFunc) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[name] = handler
+}
+
+// Lookup returns the handler registered under the given name.
+func Lookup(name string) (consumer.HandlerFunc, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[name]
+	return handler, ok
+}
+
+// Handlers returns the sorted names of the registered handlers.
+func Handlers() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,142 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/kafkatest"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"strings"
+	"testing"
+)
+
+// brokerSource replays the whole partitions of a kafkatest.Broker.
+type brokerSource struct {
+	broker *kafkatest.Broker
+}
+
+func (s brokerSource) Ranges(_ context.Context, replayer *Replayer) ([]Range, error) {
+	counts := make(map[int]int64)
+	for _, msg := range s.broker.Messages(replayer.topic) {
+		counts[msg.Partition]++
+	}
+
+	var ranges []Range
+	for partition, count := range counts {
+		rng := Range{Partition: partition, Start: 0, End: count}
+		if replayer.startOffset >= 0 {
+			rng.Start = max(rng.Start, replayer.startOffset)
+		}
+		ranges = append(ranges, rng)
+	}
+	return ranges, nil
+}
+
+func (s brokerSource) Read(_ context.Context, topic string, r Range, fn func(msg kafka.Message) error) error {
+	for _, msg := range s.broker.Messages(topic) {
+		if msg.Partition == r.Partition && msg.Offset >= r.Start && msg.Offset < r.End {
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func withSource(s source) Option {
+	return func(r *Replayer) {
+		r.source = s
+	}
+}
+
+// newCdcBroker returns a broker with CDC events of the users and roles tables.
+func newCdcBroker() *kafkatest.Broker {
+	broker := kafkatest.NewBroker(kafkatest.WithPartitions(2))
+	for _, event := range []struct{ key, table string }{
+		{"1", "users"}, {"2", "roles"}, {"3", "users"}, {"4", "users"},
+	} {
+		_ = broker.WriteMessages(context.Background(), kafka.Message{
+			Topic: "cdc",
+			Key:   []byte(event.key),
+			Value: []byte(`{"payload":{"op":"u","source":{"table":"` + event.table + `"},"after":{"id":` + event.key + `}}}`),
+		})
+	}
+	return broker
+}
+
+// TestReplayerRun is a function to test replaying filtered CDC events into another topic.
+func TestReplayerRun(t *testing.T) {
+	broker := newCdcBroker()
+
+	result, err := NewReplayer("cdc", withSource(brokerSource{broker}), WithTables("users")).
+		Run(context.Background(), ToTopic(broker, "cdc.replay"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Scanned != 4 || result.Matched != 3 || result.Replayed != 3 {
+		t.Errorf("Run failed: unexpected result %+v", result)
+	}
+	if replayed := broker.Messages("cdc.replay"); len(replayed) != 3 {
+		t.Errorf("Run failed: expected %d replayed messages but got %d", 3, len(replayed))
+	}
+}
+
+// TestReplayerDryRun is a function to test that a dry run does not call the handler.
+func TestReplayerDryRun(t *testing.T) {
+	broker := newCdcBroker()
+
+	result, err := NewReplayer("cdc", withSource(brokerSource{broker}), WithKeys("2", "3"), WithDryRun()).
+		Run(context.Background(), func(context.Context, kafka.Message) error {
+			return errors.New("handler called during dry run")
+		})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if result.Matched != 2 || result.Replayed != 0 || result.Failed != 0 {
+		t.Errorf("Run failed: unexpected result %+v", result)
+	}
+}
+
+// TestTableFilter is a function to test TableFilter function.
+func TestTableFilter(t *testing.T) {
+	filter := TableFilter("users")
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{`{"op":"c","source":{"table":"users"}}`, true},
+		{`{"op":"c","source":{"table":"roles"}}`, false},
+		{``, false},
+		{`not json`, false},
+	}
+
+	for _, test := range tests {
+		if actual := filter(kafka.Message{Value: []byte(test.value)}); actual != test.expected {
+			t.Errorf("TableFilter failed for %q: expected %v but got %v", test.value, test.expected, actual)
+		}
+	}
+}
+
+// TestRunCLI is a function to test the argument errors of RunCLI function.
+func TestRunCLI(t *testing.T) {
+	Register("test-handler", func(context.Context, kafka.Message) error {
+		return nil
+	})
+
+	if err := RunCLI(context.Background(), nil, io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("RunCLI failed: expected %v but got %v", flag.ErrHelp, err)
+	}
+
+	err := RunCLI(context.Background(), []string{"-topic", "cdc", "-handler", "unknown"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "test-handler") {
+		t.Errorf("RunCLI failed: expected unknown handler error listing test-handler but got %v", err)
+	}
+
+	if err = RunCLI(context.Background(), []string{"-topic", "cdc", "-from", "yesterday"}, io.Discard); err == nil {
+		t.Errorf("RunCLI failed: expected invalid -from error")
+	}
+}
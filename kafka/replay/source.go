@@ -0,0 +1,196 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	swekafka "github.com/ngdangkietswe/swe-go-common-shared/kafka"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"time"
+)
+
+// partitionReader reads a partition from an offset, implemented by kafka.Reader without consumer group.
+type partitionReader interface {
+	SetOffset(offset int64) error
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
+}
+
+// kafkaSource resolves the ranges with the Kafka admin API and reads each partition with its own reader.
+type kafkaSource struct {
+	client      *kafka.Client
+	newReader   func(topic string, partition int) partitionReader
+	idleTimeout time.Duration
+}
+
+func newKafkaSource() *kafkaSource {
+	clientConfig := swekafka.LoadClientConfig()
+
+	transport, err := clientConfig.Transport()
+	if err != nil {
+		panic(err)
+	}
+
+	dialer, err := clientConfig.Dialer()
+	if err != nil {
+		panic(err)
+	}
+
+	return &kafkaSource{
+		client: &kafka.Client{Addr: kafka.TCP(clientConfig.Brokers...), Transport: transport},
+		newReader: func(topic string, partition int) partitionReader {
+			return kafka.NewReader(kafka.ReaderConfig{
+				Brokers:   clientConfig.Brokers,
+				Topic:     topic,
+				Partition: partition,
+				Dialer:    dialer,
+				MinBytes:  1,
+				MaxBytes:  10e6,
+			})
+		},
+		idleTimeout: time.Duration(config.GetInt("KAFKA_REPLAY_IDLE_TIMEOUT_MS", 10000)) * time.Millisecond,
+	}
+}
+
+// Ranges intersects the offsets of the partitions with the time window and the offset range of the replayer.
+func (s *kafkaSource) Ranges(ctx context.Context, replayer *Replayer) ([]Range, error) {
+	partitions := replayer.partitions
+	if len(partitions) == 0 {
+		metadata, err := s.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{replayer.topic}})
+		if err != nil {
+			return nil, err
+		}
+		if len(metadata.Topics) == 0 || metadata.Topics[0].Error != nil {
+			return nil, fmt.Errorf("topic %s not found", replayer.topic)
+		}
+		for _, partition := range metadata.Topics[0].Partitions {
+			partitions = append(partitions, partition.ID)
+		}
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, partition := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(partition), kafka.LastOffsetOf(partition))
+	}
+
+	bounds, err := s.listOffsets(ctx, replayer.topic, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make([]Range, 0, len(partitions))
+	for _, partition := range partitions {
+		offsets := bounds[partition]
+		ranges = append(ranges, Range{Partition: partition, Start: offsets.FirstOffset, End: offsets.LastOffset})
+	}
+
+	if err = s.narrowToTime(ctx, replayer.topic, ranges, replayer.from, true); err != nil {
+		return nil, err
+	}
+	if err = s.narrowToTime(ctx, replayer.topic, ranges, replayer.to, false); err != nil {
+		return nil, err
+	}
+
+	for i := range ranges {
+		if replayer.startOffset >= 0 {
+			ranges[i].Start = max(ranges[i].Start, replayer.startOffset)
+		}
+		if replayer.endOffset >= 0 {
+			ranges[i].End = min(ranges[i].End, replayer.endOffset)
+		}
+	}
+
+	return ranges, nil
+}
+
+// narrowToTime moves the start or the end of the ranges to the first offset written at or after the time.
+func (s *kafkaSource) narrowToTime(ctx context.Context, topic string, ranges []Range, at time.Time, start bool) error {
+	if at.IsZero() {
+		return nil
+	}
+
+	requests := make([]kafka.OffsetRequest, len(ranges))
+	for i, r := range ranges {
+		requests[i] = kafka.TimeOffsetOf(r.Partition, at)
+	}
+
+	offsets, err := s.listOffsets(ctx, topic, requests)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range ranges {
+		// Without a message written after the time, the broker returns no offset or -1.
+		offset := r.End
+		for o := range offsets[r.Partition].Offsets {
+			if o >= 0 {
+				offset = o
+			}
+		}
+
+		if start {
+			ranges[i].Start = max(r.Start, offset)
+		} else {
+			ranges[i].End = min(r.End, offset)
+		}
+	}
+	return nil
+}
+
+// listOffsets returns the offsets of the requests by partition.
+func (s *kafkaSource) listOffsets(ctx context.Context, topic string, requests []kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	response, err := s.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+
+	offsets := make(map[int]kafka.PartitionOffsets)
+	for _, partition := range response.Topics[topic] {
+		if partition.Error != nil {
+			return nil, fmt.Errorf("failed to list offsets of partition %d: %w", partition.Partition, partition.Error)
+		}
+		offsets[partition.Partition] = partition
+	}
+	return offsets, nil
+}
+
+// Read reads the range of the partition without a consumer group, so no offset is committed.
+// The offsets before the end of the range may be missing because of compaction or transaction markers,
+// so the read also ends at the first message past the range, or when no message arrives within the idle timeout.
+func (s *kafkaSource) Read(ctx context.Context, topic string, r Range, fn func(msg kafka.Message) error) error {
+	reader := s.newReader(topic, r.Partition)
+	defer reader.Close()
+
+	if err := reader.SetOffset(r.Start); err != nil {
+		return err
+	}
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, s.idleTimeout)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			log.Printf("No message of topic %s partition %d within %s, ending replay before offset %d", topic, r.Partition, s.idleTimeout, r.End)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Offset >= r.End {
+			return nil
+		}
+
+		if err = fn(msg); err != nil {
+			return err
+		}
+
+		if msg.Offset >= r.End-1 {
+			return nil
+		}
+	}
+}
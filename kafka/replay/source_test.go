@@ -0,0 +1,99 @@
+package replay
+
+import (
+	"context"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/kafkatest"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// logReader is a partitionReader over the messages of a kafkatest.Broker partition, without the compacted offsets.
+// It blocks at the tail of the log like kafka.Reader.
+type logReader struct {
+	msgs   []kafka.Message
+	offset int64
+}
+
+func (r *logReader) SetOffset(offset int64) error {
+	r.offset = offset
+	return nil
+}
+
+func (r *logReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	for _, msg := range r.msgs {
+		if msg.Offset >= r.offset {
+			r.offset = msg.Offset + 1
+			return msg, nil
+		}
+	}
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *logReader) Close() error {
+	return nil
+}
+
+// newLogSource returns a kafkaSource reading the partition 0 of the topic without the compacted offsets.
+func newLogSource(broker *kafkatest.Broker, topic string, compacted ...int64) *kafkaSource {
+	var msgs []kafka.Message
+	for _, msg := range broker.Messages(topic) {
+		removed := false
+		for _, offset := range compacted {
+			removed = removed || msg.Offset == offset
+		}
+		if !removed {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return &kafkaSource{
+		newReader: func(string, int) partitionReader {
+			return &logReader{msgs: msgs}
+		},
+		idleTimeout: 50 * time.Millisecond,
+	}
+}
+
+// TestKafkaSourceRead is a function to test the range bounds of kafkaSource.Read function.
+func TestKafkaSourceRead(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	for i := 0; i < 6; i++ {
+		_ = broker.WriteMessages(context.Background(), kafka.Message{Topic: "events", Value: []byte("event")})
+	}
+
+	tests := []struct {
+		name      string
+		compacted []int64
+		rng       Range
+		expected  []int64
+	}{
+		{"whole range", nil, Range{Start: 1, End: 4}, []int64{1, 2, 3}},
+		{"gap at end", []int64{3}, Range{Start: 1, End: 4}, []int64{1, 2}},
+		{"gap at tail", []int64{5}, Range{Start: 3, End: 6}, []int64{3, 4}},
+	}
+
+	for _, test := range tests {
+		var offsets []int64
+		err := newLogSource(broker, "events", test.compacted...).Read(context.Background(), "events", test.rng, func(msg kafka.Message) error {
+			offsets = append(offsets, msg.Offset)
+			return nil
+		})
+
+		if err != nil {
+			t.Errorf("Read failed for %s: %v", test.name, err)
+		}
+		if len(offsets) != len(test.expected) {
+			t.Errorf("Read failed for %s: expected offsets %v but got %v", test.name, test.expected, offsets)
+			continue
+		}
+		for i := range offsets {
+			if offsets[i] != test.expected[i] {
+				t.Errorf("Read failed for %s: expected offsets %v but got %v", test.name, test.expected, offsets)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"time"
+)
+
+var _ producer.Scheduler = (*Scheduler)(nil)
+
+// Entry is a message waiting for its delivery time.
+type Entry struct {
+	Id      string
+	At      time.Time
+	Message kafka.Message
+
+	// Attempts is the number of failed deliveries, LastError the error of the last one.
+	Attempts  int
+	LastError string
+}
+
+// Store persists the pending entries and the leadership of the schedulers sharing it.
+type Store interface {
+	Add(ctx context.Context, entry Entry) error
+
+	// Due returns at most limit entries whose delivery time is not after now, the earliest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]Entry, error)
+	Remove(ctx context.Context, ids ...string) error
+	Pending(ctx context.Context) (int64, error)
+
+	// Retry replaces the stored entry, rescheduled at its new delivery time.
+	Retry(ctx context.Context, entry Entry) error
+
+	// DeadLetter moves the entry out of the pending entries, to the dead letters.
+	DeadLetter(ctx context.Context, entry Entry) error
+
+	// DeadLetters returns the entries whose delivery was given up.
+	DeadLetters(ctx context.Context) ([]Entry, error)
+
+	// TryLock acquires or extends the leadership for owner during ttl. Returns false if another owner holds it.
+	TryLock(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, owner string) error
+}
+
+// Writer writes messages to their topic. kafka.Writer and kafkatest.Broker satisfy it. Writes must be synchronous,
+// since the entries are removed once WriteMessages returns; kafka.WriteErrors reports the failed messages of a batch.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Scheduler delivers messages to their topic once their delivery time is due. Any number of instances can
+// share a Store; only the leader delivers. Set it as KProducer.Scheduler to enable DeliverAt and DeliverAfter:
+//
+//	delayed := scheduler.NewScheduler(scheduler.NewRedisStore(redisCache), kProducer.Writer)
+//	kProducer.Scheduler = delayed
+//	go delayed.Run(ctx)
+//
+// Messages failing to be delivered are retried with exponential backoff, without holding back the other messages,
+// and moved to the dead letters after the maximum number of attempts.
+type Scheduler struct {
+	store        Store
+	writer       Writer
+	owner        string
+	pollInterval time.Duration
+	leaderTTL    time.Duration
+	batchSize    int
+	maxAttempts  int
+	retryBackoff time.Duration
+	maxBackoff   time.Duration
+}
+
+// Option defines a function type for configuring the Scheduler.
+type Option func(*Scheduler)
+
+// WithPollInterval sets how often the leader looks for due messages.
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *Scheduler) {
+		s.pollInterval = interval
+	}
+}
+
+// WithLeaderTTL sets how long the leadership is kept without being renewed.
+func WithLeaderTTL(ttl time.Duration) Option {
+	return func(s *Scheduler) {
+		s.leaderTTL = ttl
+	}
+}
+
+// WithBatchSize sets the maximum number of messages delivered per poll.
+func WithBatchSize(batchSize int) Option {
+	return func(s *Scheduler) {
+		s.batchSize = batchSize
+	}
+}
+
+// WithRetry sets the number of delivery attempts of a message before it is dead-lettered, and the backoff
+// between them, doubled after every attempt up to maxBackoff.
+func WithRetry(maxAttempts int, backoff, maxBackoff time.Duration) Option {
+	return func(s *Scheduler) {
+		s.maxAttempts = maxAttempts
+		s.retryBackoff = backoff
+		s.maxBackoff = maxBackoff
+	}
+}
+
+// NewScheduler creates a scheduler configured from the KAFKA_SCHEDULER_* config keys and the given options.
+// It panics if the writer is an asynchronous kafka.Writer, whose writes return before the messages are delivered.
+func NewScheduler(store Store, writer Writer, options ...Option) *Scheduler {
+	if kafkaWriter, ok := writer.(*kafka.Writer); ok && kafkaWriter.Async {
+		panic("scheduler: the writer must be synchronous, scheduled messages would be removed before delivery")
+	}
+
+	scheduler := &Scheduler{
+		store:        store,
+		writer:       writer,
+		owner:        uuid.NewString(),
+		pollInterval: time.Duration(config.GetInt("KAFKA_SCHEDULER_POLL_INTERVAL_MS", 1000)) * time.Millisecond,
+		leaderTTL:    time.Duration(config.GetInt("KAFKA_SCHEDULER_LEADER_TTL_MS", 10000)) * time.Millisecond,
+		batchSize:    config.GetInt("KAFKA_SCHEDULER_BATCH_SIZE", 100),
+		maxAttempts:  config.GetInt("KAFKA_SCHEDULER_MAX_ATTEMPTS", 10),
+		retryBackoff: time.Duration(config.GetInt("KAFKA_SCHEDULER_RETRY_BACKOFF_MS", 1000)) * time.Millisecond,
+		maxBackoff:   time.Duration(config.GetInt("KAFKA_SCHEDULER_MAX_BACKOFF_MS", 3600000)) * time.Millisecond,
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(scheduler)
+	}
+
+	return scheduler
+}
+
+// Schedule stores the message until the given time.
+func (s *Scheduler) Schedule(ctx context.Context, at time.Time, msg kafka.Message) error {
+	return s.store.Add(ctx, Entry{Id: uuid.NewString(), At: at, Message: msg})
+}
+
+// Pending returns the number of messages waiting for delivery.
+func (s *Scheduler) Pending(ctx context.Context) (int64, error) {
+	return s.store.Pending(ctx)
+}
+
+// DeadLetters returns the messages whose delivery was given up after the maximum number of attempts.
+func (s *Scheduler) DeadLetters(ctx context.Context) ([]Entry, error) {
+	return s.store.DeadLetters(ctx)
+}
+
+// Run delivers the due messages while this instance is the leader, until the context is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Starting scheduler %s", s.owner)
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Hand over the leadership without waiting for its expiry.
+			unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.store.Unlock(unlockCtx, s.owner); err != nil {
+				log.Printf("Error while releasing scheduler leadership: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if _, err := s.dispatch(ctx, time.Now()); err != nil {
+				log.Printf("Error while delivering scheduled messages: %v", err)
+			}
+		}
+	}
+}
+
+// dispatch writes the due messages to their topic and removes the delivered ones from the store. A crash between
+// both steps delivers the messages again; their event-id header lets consumers detect the duplicates. The failed
+// messages are rescheduled with backoff, so that the next ones are delivered meanwhile. The leadership is extended
+// before every batch, and dispatching stops once another instance took it over; the leader TTL must therefore
+// exceed the time to write a batch.
+func (s *Scheduler) dispatch(ctx context.Context, now time.Time) (int, error) {
+	delivered := 0
+	var errs []error
+	for batch := 0; ; batch++ {
+		leader, err := s.store.TryLock(ctx, s.owner, s.leaderTTL)
+		if err != nil {
+			return delivered, errors.Join(append(errs, fmt.Errorf("failed to acquire scheduler leadership: %w", err))...)
+		}
+		if !leader {
+			if batch > 0 {
+				log.Printf("Scheduler %s lost the leadership, stopping delivery", s.owner)
+			}
+			return delivered, errors.Join(errs...)
+		}
+
+		entries, err := s.store.Due(ctx, now, s.batchSize)
+		if err != nil {
+			return delivered, errors.Join(append(errs, fmt.Errorf("failed to load due messages: %w", err))...)
+		}
+		if len(entries) == 0 {
+			return delivered, errors.Join(errs...)
+		}
+
+		msgs := make([]kafka.Message, len(entries))
+		for i, entry := range entries {
+			msgs[i] = entry.Message
+		}
+
+		writeErrs := writeErrors(s.writer.WriteMessages(ctx, msgs...), len(msgs))
+		if ctx.Err() != nil {
+			// An interrupted write is retried as is by the next leader.
+			return delivered, errors.Join(append(errs, ctx.Err())...)
+		}
+
+		var ids []string
+		for i, entry := range entries {
+			if writeErrs[i] == nil {
+				ids = append(ids, entry.Id)
+				continue
+			}
+
+			if err = s.fail(ctx, entry, writeErrs[i], now); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if err = s.store.Remove(ctx, ids...); err != nil {
+			return delivered, errors.Join(append(errs, fmt.Errorf("failed to remove delivered messages: %w", err))...)
+		}
+
+		delivered += len(ids)
+		if len(entries) < s.batchSize {
+			return delivered, errors.Join(errs...)
+		}
+	}
+}
+
+// fail reschedules an entry whose delivery failed with backoff, or dead-letters it after the maximum number of attempts.
+func (s *Scheduler) fail(ctx context.Context, entry Entry, cause error, now time.Time) error {
+	entry.Attempts++
+	entry.LastError = cause.Error()
+
+	if entry.Attempts >= s.maxAttempts {
+		log.Printf("Giving up scheduled message %s to topic %s after %d attempts: %v",
+			entry.Id, entry.Message.Topic, entry.Attempts, cause)
+		if err := s.store.DeadLetter(ctx, entry); err != nil {
+			return fmt.Errorf("failed to dead-letter message %s: %w", entry.Id, err)
+		}
+		return nil
+	}
+
+	backoff := s.retryBackoff << min(entry.Attempts-1, 30)
+	if backoff <= 0 || backoff > s.maxBackoff {
+		backoff = s.maxBackoff
+	}
+	entry.At = now.Add(backoff)
+
+	log.Printf("Error while delivering scheduled message %s to topic %s, retrying in %s: %v",
+		entry.Id, entry.Message.Topic, backoff, cause)
+	if err := s.store.Retry(ctx, entry); err != nil {
+		return fmt.Errorf("failed to reschedule message %s: %w", entry.Id, err)
+	}
+	return nil
+}
+
+// writeErrors returns the error of each message of a batch write, from kafka.WriteErrors or the error of the batch.
+func writeErrors(err error, count int) []error {
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == count {
+		return writeErrs
+	}
+
+	errs := make([]error, count)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/kafkatest"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// TestSchedulerDispatch is a function to test the delivery of due messages in order.
+func TestSchedulerDispatch(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	scheduler := NewScheduler(NewMemoryStore(), broker, WithBatchSize(1))
+	ctx := context.Background()
+	now := time.Now()
+
+	for key, at := range map[string]time.Time{
+		"second": now.Add(-time.Second),
+		"first":  now.Add(-time.Minute),
+		"later":  now.Add(time.Hour),
+	} {
+		if err := scheduler.Schedule(ctx, at, kafka.Message{Topic: constants.TopicResetPassword, Key: []byte(key)}); err != nil {
+			t.Fatalf("Schedule failed: %v", err)
+		}
+	}
+
+	delivered, err := scheduler.dispatch(ctx, now)
+	if err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	if delivered != 2 {
+		t.Errorf("dispatch failed: expected %d delivered but got %d", 2, delivered)
+	}
+
+	msgs := broker.Messages(constants.TopicResetPassword)
+	if len(msgs) != 2 || string(msgs[0].Key) != "first" || string(msgs[1].Key) != "second" {
+		t.Errorf("dispatch failed: unexpected messages %v", msgs)
+	}
+
+	if pending, _ := scheduler.Pending(ctx); pending != 1 {
+		t.Errorf("Pending failed: expected %d but got %d", 1, pending)
+	}
+}
+
+// failingWriter fails the messages of a topic and writes the others to the broker.
+type failingWriter struct {
+	broker *kafkatest.Broker
+	topic  string
+}
+
+func (w *failingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	errs := make(kafka.WriteErrors, len(msgs))
+	failed := false
+	for i, msg := range msgs {
+		if msg.Topic == w.topic {
+			errs[i], failed = errors.New("unknown topic"), true
+		} else if err := w.broker.WriteMessages(ctx, msg); err != nil {
+			errs[i], failed = err, true
+		}
+	}
+	if failed {
+		return errs
+	}
+	return nil
+}
+
+// TestSchedulerRetry is a function to test that failing messages are retried with backoff, then dead-lettered,
+// without holding back the other messages.
+func TestSchedulerRetry(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	scheduler := NewScheduler(NewMemoryStore(), &failingWriter{broker: broker, topic: "broken"},
+		WithRetry(3, time.Second, 90*time.Second))
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := scheduler.Schedule(ctx, now.Add(-time.Minute), kafka.Message{Topic: "broken", Key: []byte("failing")}); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if err := scheduler.Schedule(ctx, now.Add(-time.Second), kafka.Message{Topic: constants.TopicResetPassword, Key: []byte("next")}); err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	delivered, err := scheduler.dispatch(ctx, now)
+	if err != nil || delivered != 1 {
+		t.Fatalf("dispatch failed: expected %d delivered but got %d, %v", 1, delivered, err)
+	}
+	if msgs := broker.Messages(constants.TopicResetPassword); len(msgs) != 1 || string(msgs[0].Key) != "next" {
+		t.Errorf("dispatch failed: unexpected messages %v", msgs)
+	}
+
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		entries, _ := scheduler.store.Due(ctx, now.Add(time.Hour), 10)
+		if len(entries) != 1 || entries[0].Attempts != i+1 || !entries[0].At.Equal(now.Add(backoff)) {
+			t.Fatalf("dispatch failed: expected attempt %d at %s but got %+v", i+1, now.Add(backoff), entries)
+		}
+		if entries[0].LastError != "unknown topic" {
+			t.Errorf("dispatch failed: expected last error %q but got %q", "unknown topic", entries[0].LastError)
+		}
+
+		if delivered, _ = scheduler.dispatch(ctx, now.Add(backoff-time.Millisecond)); delivered != 0 {
+			t.Errorf("dispatch failed: expected no delivery before the backoff but got %d", delivered)
+		}
+		now = now.Add(backoff)
+		_, _ = scheduler.dispatch(ctx, now)
+	}
+
+	if pending, _ := scheduler.Pending(ctx); pending != 0 {
+		t.Errorf("Pending failed: expected %d but got %d", 0, pending)
+	}
+
+	dead, err := scheduler.DeadLetters(ctx)
+	if err != nil || len(dead) != 1 || string(dead[0].Message.Key) != "failing" || dead[0].Attempts != 3 {
+		t.Errorf("DeadLetters failed: expected the failing message after %d attempts but got %+v, %v", 3, dead, err)
+	}
+}
+
+// takeoverWriter writes to the broker, then lets another instance take over the expired leadership of the store.
+type takeoverWriter struct {
+	broker *kafkatest.Broker
+	store  *MemoryStore
+}
+
+func (w *takeoverWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.store.mu.Lock()
+	w.store.leaderUntil = time.Now()
+	w.store.mu.Unlock()
+
+	if ok, _ := w.store.TryLock(ctx, "other", time.Minute); !ok {
+		return errors.New("takeover failed")
+	}
+	return w.broker.WriteMessages(ctx, msgs...)
+}
+
+// TestSchedulerLeadershipLost is a function to test that dispatching stops once the leadership expired mid-dispatch.
+func TestSchedulerLeadershipLost(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	store := NewMemoryStore()
+	scheduler := NewScheduler(store, &takeoverWriter{broker: broker, store: store}, WithBatchSize(1))
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if err := scheduler.Schedule(ctx, now.Add(-time.Second), kafka.Message{Topic: constants.TopicResetPassword}); err != nil {
+			t.Fatalf("Schedule failed: %v", err)
+		}
+	}
+
+	delivered, err := scheduler.dispatch(ctx, now)
+	if err != nil || delivered != 1 {
+		t.Errorf("dispatch failed: expected %d delivered but got %d, %v", 1, delivered, err)
+	}
+	if pending, _ := scheduler.Pending(ctx); pending != 2 {
+		t.Errorf("Pending failed: expected %d but got %d", 2, pending)
+	}
+
+	if delivered, _ = scheduler.dispatch(ctx, now); delivered != 0 {
+		t.Errorf("dispatch failed: expected no delivery without the leadership but got %d", delivered)
+	}
+}
+
+// TestNewSchedulerAsyncWriter is a function to test that asynchronous writers are rejected.
+func TestNewSchedulerAsyncWriter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewScheduler failed: expected a panic with an asynchronous writer")
+		}
+	}()
+
+	NewScheduler(NewMemoryStore(), &kafka.Writer{Async: true})
+}
+
+// TestMemoryStoreTryLock is a function to test the single leader election of MemoryStore.
+func TestMemoryStoreTryLock(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if ok, _ := store.TryLock(ctx, "a", time.Minute); !ok {
+		t.Errorf("TryLock failed: expected a to lead")
+	}
+	if ok, _ := store.TryLock(ctx, "b", time.Minute); ok {
+		t.Errorf("TryLock failed: expected b not to lead while a holds the lock")
+	}
+	if ok, _ := store.TryLock(ctx, "a", time.Minute); !ok {
+		t.Errorf("TryLock failed: expected a to extend its lock")
+	}
+
+	_ = store.Unlock(ctx, "a")
+	if ok, _ := store.TryLock(ctx, "b", time.Minute); !ok {
+		t.Errorf("TryLock failed: expected b to lead after a released the lock")
+	}
+}
+
+// TestKProducerDeliverAfter is a function to test that delayed messages are handed to the scheduler.
+func TestKProducerDeliverAfter(t *testing.T) {
+	store := NewMemoryStore()
+	kProducer := &producer.KProducer{Scheduler: NewScheduler(store, kafkatest.NewBroker())}
+
+	err := kProducer.Publish(context.Background(), "user-1", constants.TopicResetPassword, "remind", producer.DeliverAfter(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	entries, _ := store.Due(context.Background(), time.Now().Add(25*time.Hour), 10)
+	if len(entries) != 1 {
+		t.Fatalf("Publish failed: expected %d scheduled message but got %d", 1, len(entries))
+	}
+
+	if entry := entries[0]; string(entry.Message.Key) != "user-1" || string(entry.Message.Value) != `"remind"` {
+		t.Errorf("Publish failed: unexpected scheduled message %+v", entry.Message)
+	}
+	if len(entries[0].Message.Headers) == 0 || entries[0].Message.Headers[0].Key != constants.KafkaHeaderEventId {
+		t.Errorf("Publish failed: expected an %s header", constants.KafkaHeaderEventId)
+	}
+}
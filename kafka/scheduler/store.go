@@ -0,0 +1,356 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// lockScript acquires the leadership if free, or extends it if already held by the owner.
+var lockScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if not current then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`)
+
+// unlockScript releases the leadership only if held by the owner.
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+// message is the stored form of a scheduled kafka.Message.
+type message struct {
+	Topic     string         `json:"topic"`
+	Key       []byte         `json:"key"`
+	Value     []byte         `json:"value"`
+	Headers   []kafka.Header `json:"headers"`
+	At        int64          `json:"at,omitempty"`
+	Attempts  int            `json:"attempts,omitempty"`
+	LastError string         `json:"last_error,omitempty"`
+}
+
+// marshalEntry returns the stored form of the entry.
+func marshalEntry(entry Entry) ([]byte, error) {
+	return json.Marshal(message{
+		Topic:     entry.Message.Topic,
+		Key:       entry.Message.Key,
+		Value:     entry.Message.Value,
+		Headers:   entry.Message.Headers,
+		At:        entry.At.UnixMilli(),
+		Attempts:  entry.Attempts,
+		LastError: entry.LastError,
+	})
+}
+
+// unmarshalEntry returns the entry of the stored form.
+func unmarshalEntry(id, data string) (Entry, error) {
+	var msg message
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return Entry{}, fmt.Errorf("failed to unmarshal scheduled message %s: %w", id, err)
+	}
+
+	return Entry{
+		Id: id,
+		At: time.UnixMilli(msg.At),
+		Message: kafka.Message{
+			Topic:   msg.Topic,
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: msg.Headers,
+		},
+		Attempts:  msg.Attempts,
+		LastError: msg.LastError,
+	}, nil
+}
+
+// RedisStore is a Store sharing the connection of the cache. Entries are indexed by delivery time in the
+// kafka_scheduler:queue sorted set and stored in the kafka_scheduler:messages hash, so they survive restarts.
+// Dead letters are kept in the kafka_scheduler:dead hash.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(redisCache *cache.RedisCache) *RedisStore {
+	return &RedisStore{client: redisCache.Client()}
+}
+
+// Add stores the entry.
+func (s *RedisStore) Add(ctx context.Context, entry Entry) error {
+	data, err := marshalEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, redisKey("messages"), entry.Id, data)
+	pipe.ZAdd(ctx, redisKey("queue"), redis.Z{Score: float64(entry.At.UnixMilli()), Member: entry.Id})
+	if _, err = pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store scheduled message: %w", err)
+	}
+	return nil
+}
+
+// Due returns the due entries, the earliest first.
+func (s *RedisStore) Due(ctx context.Context, now time.Time, limit int) ([]Entry, error) {
+	scores, err := s.client.ZRangeByScoreWithScores(ctx, redisKey("queue"), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil || len(scores) == 0 {
+		return nil, err
+	}
+
+	ids := make([]string, len(scores))
+	for i, score := range scores {
+		ids[i] = score.Member.(string)
+	}
+
+	values, err := s.client.HMGet(ctx, redisKey("messages"), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	entries := make([]Entry, 0, len(ids))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			orphans = append(orphans, ids[i])
+			continue
+		}
+
+		entry, err := unmarshalEntry(ids[i], data)
+		if err != nil {
+			return nil, err
+		}
+		entry.At = time.UnixMilli(int64(scores[i].Score))
+		entries = append(entries, entry)
+	}
+
+	// Drop the index entries whose message is gone.
+	if err = s.Remove(ctx, orphans...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Remove deletes the entries.
+func (s *RedisStore) Remove(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.ZRem(ctx, redisKey("queue"), members...)
+	pipe.HDel(ctx, redisKey("messages"), ids...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Pending returns the number of stored entries.
+func (s *RedisStore) Pending(ctx context.Context) (int64, error) {
+	return s.client.ZCard(ctx, redisKey("queue")).Result()
+}
+
+// Retry stores the entry and moves it to its new delivery time.
+func (s *RedisStore) Retry(ctx context.Context, entry Entry) error {
+	return s.Add(ctx, entry)
+}
+
+// DeadLetter moves the entry from the queue to the dead letters.
+func (s *RedisStore) DeadLetter(ctx context.Context, entry Entry) error {
+	data, err := marshalEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, redisKey("dead"), entry.Id, data)
+	pipe.ZRem(ctx, redisKey("queue"), entry.Id)
+	pipe.HDel(ctx, redisKey("messages"), entry.Id)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to dead-letter scheduled message: %w", err)
+	}
+	return nil
+}
+
+// DeadLetters returns the dead letters.
+func (s *RedisStore) DeadLetters(ctx context.Context) ([]Entry, error) {
+	values, err := s.client.HGetAll(ctx, redisKey("dead")).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(values))
+	for id, data := range values {
+		entry, err := unmarshalEntry(id, data)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+	return entries, nil
+}
+
+// TryLock acquires or extends the leadership.
+func (s *RedisStore) TryLock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	return lockScript.Run(ctx, s.client, []string{redisKey("leader")}, owner, ttl.Milliseconds()).Bool()
+}
+
+// Unlock releases the leadership if held by the owner.
+func (s *RedisStore) Unlock(ctx context.Context, owner string) error {
+	err := unlockScript.Run(ctx, s.client, []string{redisKey("leader")}, owner).Err()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+func redisKey(name string) string {
+	return fmt.Sprintf("%s:%s", constants.KafkaSchedulerCacheKeyPrefix, name)
+}
+
+// MemoryStore is an in-process Store, suitable for tests and single instance deployments. Entries do not
+// survive restarts.
+type MemoryStore struct {
+	mu          sync.Mutex
+	entries     map[string]Entry
+	dead        map[string]Entry
+	owner       string
+	leaderUntil time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry), dead: make(map[string]Entry)}
+}
+
+// Add stores the entry.
+func (s *MemoryStore) Add(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[entry.Id] = entry
+	return nil
+}
+
+// Due returns the due entries, the earliest first.
+func (s *MemoryStore) Due(_ context.Context, now time.Time, limit int) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []Entry
+	for _, entry := range s.entries {
+		if !entry.At.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// Remove deletes the entries.
+func (s *MemoryStore) Remove(_ context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.entries, id)
+	}
+	return nil
+}
+
+// Pending returns the number of stored entries.
+func (s *MemoryStore) Pending(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.entries)), nil
+}
+
+// Retry replaces the entry.
+func (s *MemoryStore) Retry(ctx context.Context, entry Entry) error {
+	return s.Add(ctx, entry)
+}
+
+// DeadLetter moves the entry to the dead letters.
+func (s *MemoryStore) DeadLetter(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, entry.Id)
+	s.dead[entry.Id] = entry
+	return nil
+}
+
+// DeadLetters returns the dead letters.
+func (s *MemoryStore) DeadLetters(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.dead))
+	for _, entry := range s.dead {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+	return entries, nil
+}
+
+// TryLock acquires or extends the leadership.
+func (s *MemoryStore) TryLock(_ context.Context, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.owner != owner && now.Before(s.leaderUntil) {
+		return false, nil
+	}
+
+	s.owner = owner
+	s.leaderUntil = now.Add(ttl)
+	return true, nil
+}
+
+// Unlock releases the leadership if held by the owner.
+func (s *MemoryStore) Unlock(_ context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owner == owner {
+		s.owner = ""
+		s.leaderUntil = time.Time{}
+	}
+	return nil
+}
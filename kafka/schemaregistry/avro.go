@@ -0,0 +1,118 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/linkedin/goavro/v2"
+	"sync"
+)
+
+// AvroSerializer encodes values with an Avro schema in the Confluent wire format.
+// Values are converted through their JSON form, which must match the Avro JSON encoding of the schema.
+// The schema is registered as written, keeping the defaults, docs, aliases and logical types that the
+// compatibility checks of the registry rely on.
+type AvroSerializer struct {
+	client *Client
+	config *serdeConfig
+	schema *Schema
+	codec  *goavro.Codec
+}
+
+func NewAvroSerializer(client *Client, schema string, options ...SerdeOption) (*AvroSerializer, error) {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+
+	return &AvroSerializer{
+		client: client,
+		config: newSerdeConfig(options...),
+		schema: &Schema{Schema: schema},
+		codec:  codec,
+	}, nil
+}
+
+// Serialize encodes the value for the topic.
+func (s *AvroSerializer) Serialize(topic string, value interface{}) ([]byte, error) {
+	id, err := s.config.schemaId(s.client, topic, s.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	textual, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := s.codec.NativeFromTextual(textual)
+	if err != nil {
+		return nil, fmt.Errorf("value does not match avro schema: %w", err)
+	}
+
+	return s.codec.BinaryFromNative(encodeHeader(id), native)
+}
+
+// AvroDeserializer decodes values encoded with any Avro schema of the registry.
+type AvroDeserializer struct {
+	client *Client
+
+	mu     sync.RWMutex
+	codecs map[int]*goavro.Codec
+}
+
+func NewAvroDeserializer(client *Client) *AvroDeserializer {
+	return &AvroDeserializer{
+		client: client,
+		codecs: make(map[int]*goavro.Codec),
+	}
+}
+
+// Deserialize decodes the data into target using the writer schema referenced by the data.
+func (d *AvroDeserializer) Deserialize(_ string, data []byte, target interface{}) error {
+	id, payload, err := decodeHeader(data)
+	if err != nil {
+		return err
+	}
+
+	codec, err := d.codec(id)
+	if err != nil {
+		return err
+	}
+
+	native, _, err := codec.NativeFromBinary(payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode avro value: %w", err)
+	}
+
+	textual, err := codec.TextualFromNative(nil, native)
+	if err != nil {
+		return fmt.Errorf("failed to decode avro value: %w", err)
+	}
+
+	return json.Unmarshal(textual, target)
+}
+
+// codec returns the cached codec of the schema ID.
+func (d *AvroDeserializer) codec(id int) (*goavro.Codec, error) {
+	d.mu.RLock()
+	codec, ok := d.codecs[id]
+	d.mu.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	schema, err := d.client.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec, err = goavro.NewCodec(schema.Schema); err != nil {
+		return nil, fmt.Errorf("invalid avro schema %d: %w", id, err)
+	}
+
+	d.mu.Lock()
+	d.codecs[id] = codec
+	d.mu.Unlock()
+
+	return codec, nil
+}
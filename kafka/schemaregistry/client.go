@@ -0,0 +1,231 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+
+	contentType = "application/vnd.schemaregistry.v1+json"
+
+	// errorCodeSubjectNotFound is returned by the registry for unknown subjects.
+	errorCodeSubjectNotFound = 40401
+)
+
+// Schema is a schema as stored in the registry.
+type Schema struct {
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Reference points to another registered schema imported by a schema.
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Error is an error response of the registry.
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("schema registry error %d: %s", e.Code, e.Message)
+}
+
+// Client is a client of the Confluent Schema Registry HTTP API. Registered schemas and IDs are cached.
+type Client struct {
+	url        string
+	username   string
+	password   string
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	idsBySchema map[string]int
+	schemasById map[int]*Schema
+}
+
+// Option defines a function type for configuring the Client.
+type Option func(*Client)
+
+// WithURL sets the base URL of the registry.
+func WithURL(url string) Option {
+	return func(c *Client) {
+		c.url = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithBasicAuth sets the credentials sent to the registry.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to call the registry.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// NewClient creates a registry client configured from the SCHEMA_REGISTRY_* config keys and the given options.
+func NewClient(options ...Option) *Client {
+	client := &Client{
+		url:         strings.TrimSuffix(config.GetString("SCHEMA_REGISTRY_URL", "http://localhost:8081"), "/"),
+		username:    config.GetString("SCHEMA_REGISTRY_USERNAME", ""),
+		password:    config.GetString("SCHEMA_REGISTRY_PASSWORD", ""),
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		idsBySchema: make(map[string]int),
+		schemasById: make(map[int]*Schema),
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(client)
+	}
+
+	return client
+}
+
+// Register registers the schema under the subject and returns its ID.
+// Registering an already registered schema returns the existing ID.
+func (c *Client) Register(subject string, schema *Schema) (int, error) {
+	cacheKey := subject + "\x00" + schema.Schema
+
+	c.mu.RLock()
+	id, ok := c.idsBySchema[cacheKey]
+	c.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	var resp struct {
+		Id int `json:"id"`
+	}
+	if err := c.do(http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schema, &resp); err != nil {
+		return 0, fmt.Errorf("failed to register schema for subject %s: %w", subject, err)
+	}
+
+	c.mu.Lock()
+	c.idsBySchema[cacheKey] = resp.Id
+	c.schemasById[resp.Id] = schema
+	c.mu.Unlock()
+
+	return resp.Id, nil
+}
+
+// GetById returns the schema registered with the given ID.
+func (c *Client) GetById(id int) (*Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemasById[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema = &Schema{}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, schema); err != nil {
+		return nil, fmt.Errorf("failed to get schema %d: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.schemasById[id] = schema
+	c.mu.Unlock()
+
+	return schema, nil
+}
+
+// GetLatest returns the ID and the schema of the latest version registered under the subject.
+func (c *Client) GetLatest(subject string) (int, *Schema, error) {
+	var resp struct {
+		Schema
+		Id int `json:"id"`
+	}
+	if err := c.do(http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp); err != nil {
+		return 0, nil, fmt.Errorf("failed to get latest schema for subject %s: %w", subject, err)
+	}
+
+	schema := resp.Schema
+	c.mu.Lock()
+	c.schemasById[resp.Id] = &schema
+	c.mu.Unlock()
+
+	return resp.Id, &schema, nil
+}
+
+// IsCompatible checks the schema against the latest version of the subject.
+// A subject without any version accepts every schema.
+func (c *Client) IsCompatible(subject string, schema *Schema) (bool, error) {
+	var resp struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+
+	err := c.do(http.MethodPost, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", schema, &resp)
+	var registryErr *Error
+	if errors.As(err, &registryErr) && registryErr.Code == errorCodeSubjectNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check compatibility for subject %s: %w", subject, err)
+	}
+
+	return resp.IsCompatible, nil
+}
+
+// do sends a request to the registry and decodes the JSON response into out.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		registryErr := &Error{StatusCode: resp.StatusCode}
+		if err = json.NewDecoder(resp.Body).Decode(registryErr); err != nil {
+			registryErr.Message = resp.Status
+		}
+		return registryErr
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
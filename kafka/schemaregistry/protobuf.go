@@ -0,0 +1,130 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"reflect"
+)
+
+// ProtobufSerializer encodes protobuf messages in the Confluent wire format.
+// Without a schema source the latest version registered for the subject is used.
+type ProtobufSerializer struct {
+	client *Client
+	config *serdeConfig
+	schema *Schema
+}
+
+func NewProtobufSerializer(client *Client, options ...SerdeOption) *ProtobufSerializer {
+	return &ProtobufSerializer{
+		client: client,
+		config: newSerdeConfig(options...),
+	}
+}
+
+// WithSchemaSource sets the .proto source registered for the messages.
+func (s *ProtobufSerializer) WithSchemaSource(source string, references ...Reference) *ProtobufSerializer {
+	s.schema = &Schema{Schema: source, SchemaType: SchemaTypeProtobuf, References: references}
+	return s
+}
+
+// Serialize encodes the protobuf message for the topic.
+func (s *ProtobufSerializer) Serialize(topic string, value interface{}) ([]byte, error) {
+	msg, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("value of type %T is not a protobuf message", value)
+	}
+
+	id, err := s.config.schemaId(s.client, topic, s.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	data := appendMessageIndexes(encodeHeader(id), msg.ProtoReflect().Descriptor())
+	return proto.MarshalOptions{}.MarshalAppend(data, msg)
+}
+
+// ProtobufDeserializer decodes protobuf messages in the Confluent wire format into a target proto.Message.
+type ProtobufDeserializer struct{}
+
+func NewProtobufDeserializer() *ProtobufDeserializer {
+	return &ProtobufDeserializer{}
+}
+
+// Deserialize decodes the data into target, which must be a proto.Message.
+func (d *ProtobufDeserializer) Deserialize(_ string, data []byte, target interface{}) error {
+	msg, err := asProtoMessage(target)
+	if err != nil {
+		return err
+	}
+
+	_, payload, err := decodeHeader(data)
+	if err != nil {
+		return err
+	}
+
+	if payload, err = skipMessageIndexes(payload); err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(payload, msg)
+}
+
+// asProtoMessage returns the target as a proto.Message. A pointer to a nil message pointer, as passed by
+// consumer.TypedWith for message types, is allocated.
+func asProtoMessage(target interface{}) (proto.Message, error) {
+	if msg, ok := target.(proto.Message); ok {
+		return msg, nil
+	}
+
+	value := reflect.ValueOf(target)
+	if value.Kind() == reflect.Ptr && value.Elem().Kind() == reflect.Ptr {
+		elem := reflect.New(value.Elem().Type().Elem())
+		if msg, ok := elem.Interface().(proto.Message); ok {
+			value.Elem().Set(elem)
+			return msg, nil
+		}
+	}
+
+	return nil, fmt.Errorf("target of type %T is not a protobuf message", target)
+}
+
+// appendMessageIndexes writes the path of the message within its .proto file as zigzag varints.
+// The common case of the first top-level message is written as a single 0.
+func appendMessageIndexes(data []byte, descriptor protoreflect.MessageDescriptor) []byte {
+	var indexes []int
+	for d := protoreflect.Descriptor(descriptor); ; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		indexes = append([]int{d.Index()}, indexes...)
+	}
+
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(data, 0)
+	}
+
+	data = binary.AppendVarint(data, int64(len(indexes)))
+	for _, index := range indexes {
+		data = binary.AppendVarint(data, int64(index))
+	}
+	return data
+}
+
+// skipMessageIndexes returns the payload following the message indexes.
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 {
+		return nil, ErrInvalidWireFormat
+	}
+	data = data[n:]
+
+	for i := int64(0); i < count; i++ {
+		if _, n = binary.Varint(data); n <= 0 {
+			return nil, ErrInvalidWireFormat
+		}
+		data = data[n:]
+	}
+	return data, nil
+}
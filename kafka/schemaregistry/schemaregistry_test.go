@@ -0,0 +1,249 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/ngdangkietswe/swe-protobuf-shared/generated/common"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const registerUserSchema = `{
+	"type": "record",
+	"name": "RegisterUser",
+	"fields": [
+		{"name": "username", "type": "string"},
+		{"name": "email", "type": "string"},
+		{"name": "created_at", "type": "string"}
+	]
+}`
+
+// fakeRegistry is a minimal in-memory stand-in for the Schema Registry HTTP API.
+type fakeRegistry struct {
+	mu           sync.Mutex
+	schemas      []Schema
+	subjects     map[string][]int
+	incompatible bool
+	requests     int
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, *Client) {
+	registry := &fakeRegistry{subjects: make(map[string][]int)}
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	return registry, NewClient(WithURL(server.URL))
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && parts[0] == "subjects" && len(parts) == 3:
+		var schema Schema
+		_ = json.NewDecoder(r.Body).Decode(&schema)
+		f.schemas = append(f.schemas, schema)
+		id := len(f.schemas)
+		f.subjects[parts[1]] = append(f.subjects[parts[1]], id)
+		writeJSON(w, http.StatusOK, map[string]int{"id": id})
+
+	case r.Method == http.MethodGet && parts[0] == "schemas":
+		var id int
+		_, _ = fmt.Sscanf(parts[2], "%d", &id)
+		if id < 1 || id > len(f.schemas) {
+			writeJSON(w, http.StatusNotFound, Error{Code: 40403, Message: "Schema not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, f.schemas[id-1])
+
+	case r.Method == http.MethodGet && parts[0] == "subjects":
+		ids := f.subjects[parts[1]]
+		if len(ids) == 0 {
+			writeJSON(w, http.StatusNotFound, Error{Code: errorCodeSubjectNotFound, Message: "Subject not found"})
+			return
+		}
+		id := ids[len(ids)-1]
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": id, "schema": f.schemas[id-1].Schema, "schemaType": f.schemas[id-1].SchemaType})
+
+	case r.Method == http.MethodPost && parts[0] == "compatibility":
+		if len(f.subjects[parts[2]]) == 0 {
+			writeJSON(w, http.StatusNotFound, Error{Code: errorCodeSubjectNotFound, Message: "Subject not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"is_compatible": !f.incompatible})
+
+	default:
+		writeJSON(w, http.StatusNotFound, Error{Code: 404, Message: "Not found"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// TestAvroSerde is a function to test the round trip of AvroSerializer and AvroDeserializer.
+func TestAvroSerde(t *testing.T) {
+	registry, client := newFakeRegistry(t)
+
+	serializer, err := NewAvroSerializer(client, registerUserSchema)
+	if err != nil {
+		t.Fatalf("NewAvroSerializer failed: %v", err)
+	}
+
+	user := domain.RegisterUser{Username: "john", Email: "john@example.com", CreatedAt: "2025-01-01"}
+	data, err := serializer.Serialize(constants.TopicRegisterUser, user)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	if data[0] != magicByte || data[4] != 1 {
+		t.Errorf("Serialize failed: expected magic byte and schema id 1 but got %v", data[:5])
+	}
+
+	if _, ok := registry.subjects[constants.TopicRegisterUser+"-value"]; !ok {
+		t.Errorf("Serialize failed: expected subject %s to be registered", constants.TopicRegisterUser+"-value")
+	}
+
+	// The registered ID is cached.
+	requests := registry.requests
+	if _, err = serializer.Serialize(constants.TopicRegisterUser, user); err != nil || registry.requests != requests {
+		t.Errorf("Serialize failed: expected cached schema id (err=%v)", err)
+	}
+
+	var decoded domain.RegisterUser
+	if err = NewAvroDeserializer(NewClient(WithURL(client.url))).Deserialize(constants.TopicRegisterUser, data, &decoded); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	if decoded != user {
+		t.Errorf("Deserialize failed: expected %+v but got %+v", user, decoded)
+	}
+}
+
+// TestAvroSerializerSchema is a function to test that the schema is registered as written, with its defaults.
+func TestAvroSerializerSchema(t *testing.T) {
+	registry, client := newFakeRegistry(t)
+
+	schema := `{
+	"type": "record",
+	"name": "RegisterUser",
+	"doc": "A user registration.",
+	"fields": [
+		{"name": "username", "type": "string"},
+		{"name": "email", "type": "string"},
+		{"name": "created_at", "type": "string"},
+		{"name": "referrer", "type": "string", "default": ""}
+	]
+}`
+
+	serializer, err := NewAvroSerializer(client, schema)
+	if err != nil {
+		t.Fatalf("NewAvroSerializer failed: %v", err)
+	}
+
+	if _, err = serializer.Serialize(constants.TopicRegisterUser, map[string]string{
+		"username": "john", "email": "john@example.com", "created_at": "2025-01-01", "referrer": "",
+	}); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	if len(registry.schemas) != 1 || registry.schemas[0].Schema != schema {
+		t.Errorf("Serialize failed: expected the schema registered as written but got %v", registry.schemas)
+	}
+}
+
+// TestCompatibilityCheck is a function to test WithCompatibilityCheck option.
+func TestCompatibilityCheck(t *testing.T) {
+	registry, client := newFakeRegistry(t)
+
+	serializer, err := NewAvroSerializer(client, registerUserSchema, WithCompatibilityCheck(true))
+	if err != nil {
+		t.Fatalf("NewAvroSerializer failed: %v", err)
+	}
+
+	// The first version of a subject is always compatible.
+	user := domain.RegisterUser{Username: "john"}
+	if _, err = serializer.Serialize(constants.TopicRegisterUser, user); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	registry.incompatible = true
+	if _, err = serializer.Serialize(constants.TopicResetPassword, user); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	// Cached IDs skip the check, it only runs before registering.
+	requests := registry.requests
+	if _, err = serializer.Serialize(constants.TopicRegisterUser, user); err != nil || registry.requests != requests {
+		t.Errorf("Serialize failed: expected cached schema id but got %d requests (err=%v)", registry.requests-requests, err)
+	}
+
+	client.idsBySchema = make(map[string]int)
+	serializer.config.ids = make(map[string]resolvedId)
+	if _, err = serializer.Serialize(constants.TopicRegisterUser, user); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Errorf("Serialize failed: expected ErrIncompatibleSchema but got %v", err)
+	}
+}
+
+// TestLatestSchemaId is a function to test the cache of the latest schema IDs.
+func TestLatestSchemaId(t *testing.T) {
+	registry, client := newFakeRegistry(t)
+	if _, err := client.Register("users-value", &Schema{Schema: registerUserSchema}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	config := newSerdeConfig(WithAutoRegister(false), WithLatestTTL(time.Hour))
+	requests := registry.requests
+	for i := 0; i < 3; i++ {
+		if id, err := config.schemaId(client, "users", nil); err != nil || id != 1 {
+			t.Fatalf("schemaId failed: expected 1 but got %d (err=%v)", id, err)
+		}
+	}
+	if registry.requests-requests != 1 {
+		t.Errorf("schemaId failed: expected 1 request but got %d", registry.requests-requests)
+	}
+
+	// Expired latest versions are resolved again.
+	config.latestTTL = 0
+	config.ids = make(map[string]resolvedId)
+	_, _ = config.schemaId(client, "users", nil)
+	_, _ = config.schemaId(client, "users", nil)
+	if registry.requests-requests != 3 {
+		t.Errorf("schemaId failed: expected 3 requests but got %d", registry.requests-requests)
+	}
+}
+
+// TestProtobufSerde is a function to test the round trip of ProtobufSerializer and ProtobufDeserializer.
+func TestProtobufSerde(t *testing.T) {
+	_, client := newFakeRegistry(t)
+
+	serializer := NewProtobufSerializer(client).WithSchemaSource(`syntax = "proto3"; message Pageable { int32 page = 1; }`)
+	data, err := serializer.Serialize("common.pageable.v1", &common.Pageable{Page: 2, Size: 20, Sort: "name"})
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	var decoded *common.Pageable
+	if err = NewProtobufDeserializer().Deserialize("common.pageable.v1", data, &decoded); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	if decoded.Page != 2 || decoded.Size != 20 || decoded.Sort != "name" {
+		t.Errorf("Deserialize failed: unexpected message %v", decoded)
+	}
+
+	if err = NewProtobufDeserializer().Deserialize("common.pageable.v1", []byte{1, 2}, &decoded); !errors.Is(err, ErrInvalidWireFormat) {
+		t.Errorf("Deserialize failed: expected ErrInvalidWireFormat but got %v", err)
+	}
+}
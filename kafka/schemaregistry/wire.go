@@ -0,0 +1,175 @@
+package schemaregistry
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// magicByte prefixes every message in the Confluent wire format.
+const magicByte byte = 0
+
+var (
+	// ErrInvalidWireFormat is returned when data does not start with the magic byte and a schema ID.
+	ErrInvalidWireFormat = errors.New("schemaregistry: invalid wire format")
+
+	// ErrIncompatibleSchema is returned when a schema is not compatible with the latest version of its subject.
+	ErrIncompatibleSchema = errors.New("schemaregistry: incompatible schema")
+)
+
+// SubjectNameStrategy derives the subject of the schema from the topic.
+type SubjectNameStrategy func(topic string) string
+
+// TopicNameStrategy is the default strategy, using "<topic>-value".
+func TopicNameStrategy(topic string) string {
+	return topic + "-value"
+}
+
+// serdeConfig holds the settings shared by the serializers, and the schema IDs they resolved.
+type serdeConfig struct {
+	autoRegister       bool
+	compatibilityCheck bool
+	subjectName        SubjectNameStrategy
+	latestTTL          time.Duration
+
+	mu  sync.Mutex
+	ids map[string]resolvedId
+}
+
+// resolvedId is a cached schema ID. Registered schemas never expire, latest versions after the latest TTL.
+type resolvedId struct {
+	id        int
+	expiresAt time.Time
+}
+
+// SerdeOption defines a function type for configuring the serializers.
+type SerdeOption func(*serdeConfig)
+
+// WithAutoRegister sets whether unknown schemas are registered. When disabled, the latest version of the subject is used.
+func WithAutoRegister(autoRegister bool) SerdeOption {
+	return func(c *serdeConfig) {
+		c.autoRegister = autoRegister
+	}
+}
+
+// WithCompatibilityCheck checks schemas against the latest version of their subject before registering them.
+func WithCompatibilityCheck(check bool) SerdeOption {
+	return func(c *serdeConfig) {
+		c.compatibilityCheck = check
+	}
+}
+
+// WithLatestTTL sets how long the latest version of a subject is cached, when schemas are not registered. Defaults to 5 minutes.
+func WithLatestTTL(ttl time.Duration) SerdeOption {
+	return func(c *serdeConfig) {
+		c.latestTTL = ttl
+	}
+}
+
+// WithSubjectNameStrategy sets how the subject is derived from the topic.
+func WithSubjectNameStrategy(strategy SubjectNameStrategy) SerdeOption {
+	return func(c *serdeConfig) {
+		c.subjectName = strategy
+	}
+}
+
+func newSerdeConfig(options ...SerdeOption) *serdeConfig {
+	c := &serdeConfig{
+		autoRegister: true,
+		subjectName:  TopicNameStrategy,
+		latestTTL:    5 * time.Minute,
+		ids:          make(map[string]resolvedId),
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// schemaId returns the ID of the schema for the topic, registering it if enabled.
+// A nil schema resolves to the latest version of the subject. IDs are cached per subject and schema fingerprint,
+// so the registry, and the compatibility check, is only called on a cache miss.
+func (c *serdeConfig) schemaId(client *Client, topic string, schema *Schema) (int, error) {
+	subject := c.subjectName(topic)
+	latest := schema == nil || !c.autoRegister
+
+	cacheKey := subject + "\x00latest"
+	if !latest {
+		cacheKey = subject + "\x00" + fingerprint(schema)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.ids[cacheKey]
+	c.mu.Unlock()
+	if ok && (cached.expiresAt.IsZero() || time.Now().Before(cached.expiresAt)) {
+		return cached.id, nil
+	}
+
+	var id int
+	var err error
+	if latest {
+		id, _, err = client.GetLatest(subject)
+	} else {
+		id, err = c.register(client, subject, schema)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	resolved := resolvedId{id: id}
+	if latest {
+		resolved.expiresAt = time.Now().Add(c.latestTTL)
+	}
+
+	c.mu.Lock()
+	c.ids[cacheKey] = resolved
+	c.mu.Unlock()
+
+	return id, nil
+}
+
+// register registers the schema under the subject, once checked against its latest version if enabled.
+func (c *serdeConfig) register(client *Client, subject string, schema *Schema) (int, error) {
+	if c.compatibilityCheck {
+		compatible, err := client.IsCompatible(subject, schema)
+		if err != nil {
+			return 0, err
+		}
+		if !compatible {
+			return 0, fmt.Errorf("%w: subject %s", ErrIncompatibleSchema, subject)
+		}
+	}
+
+	return client.Register(subject, schema)
+}
+
+// fingerprint returns the SHA-256 of the schema, its type and its references.
+func fingerprint(schema *Schema) string {
+	data, _ := json.Marshal(schema)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeHeader writes the magic byte and the schema ID.
+func encodeHeader(id int) []byte {
+	header := make([]byte, 5, 64)
+	header[0] = magicByte
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return header
+}
+
+// decodeHeader reads the magic byte and the schema ID, returning the remaining payload.
+func decodeHeader(data []byte) (int, []byte, error) {
+	if len(data) < 5 || data[0] != magicByte {
+		return 0, nil, ErrInvalidWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
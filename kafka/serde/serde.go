@@ -0,0 +1,26 @@
+package serde
+
+import "encoding/json"
+
+// Serializer encodes message values produced to a topic.
+type Serializer interface {
+	Serialize(topic string, value interface{}) ([]byte, error)
+}
+
+// Deserializer decodes message values consumed from a topic into target.
+type Deserializer interface {
+	Deserialize(topic string, data []byte, target interface{}) error
+}
+
+// JSON is the default serde, encoding values as plain JSON.
+type JSON struct{}
+
+// Serialize encodes the value as JSON.
+func (JSON) Serialize(_ string, value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Deserialize decodes the JSON data into target.
+func (JSON) Deserialize(_ string, data []byte, target interface{}) error {
+	return json.Unmarshal(data, target)
+}
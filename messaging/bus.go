@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"time"
+)
+
+const (
+	BackendKafka  = "kafka"
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+var (
+	_ Bus = (*KafkaBus)(nil)
+	_ Bus = (*RedisBus)(nil)
+	_ Bus = (*MemoryBus)(nil)
+)
+
+// ErrClosed is returned when publishing to or subscribing on a closed bus.
+var ErrClosed = errors.New("messaging: bus closed")
+
+// Message is a message delivered by a Bus, independently of the backend.
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+	Time    time.Time
+}
+
+// Decode decodes the JSON value of the message into target.
+func (m *Message) Decode(target interface{}) error {
+	return json.Unmarshal(m.Value, target)
+}
+
+// Handler processes a message. A returned error is logged, and the Redis backend leaves the message pending.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Bus publishes and subscribes to topics over a pluggable backend.
+type Bus interface {
+	// Publish encodes data as JSON and publishes it to the topic.
+	Publish(ctx context.Context, key, topic string, data interface{}) error
+
+	// Subscribe delivers the messages of the topic to the handler until the context is done.
+	// Subscribers sharing a group split the messages between them.
+	Subscribe(ctx context.Context, topic, group string, handler Handler) error
+
+	// Close releases the resources of the bus.
+	Close() error
+}
+
+// NewBus creates the bus selected by the MESSAGING_BACKEND config key: kafka (default), redis or memory.
+func NewBus() (Bus, error) {
+	switch backend := config.GetString("MESSAGING_BACKEND", BackendKafka); backend {
+	case BackendKafka:
+		return NewKafkaBus(), nil
+	case BackendRedis:
+		redisCache := cache.NewRedisCache()
+		if redisCache == nil {
+			return nil, fmt.Errorf("failed to connect to Redis for messaging backend")
+		}
+		return NewRedisBus(redisCache), nil
+	case BackendMemory:
+		return NewMemoryBus(), nil
+	default:
+		return nil, fmt.Errorf("unsupported messaging backend: %s", backend)
+	}
+}
@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/consumer"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"log"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// groupReader reads a topic as a member of a consumer group. kafka.Reader and kafkatest.Subscriber satisfy it.
+type groupReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaBus is a Bus backed by KProducer and KConsumer.
+type KafkaBus struct {
+	publisher     producer.Publisher
+	newSubscriber func(topic, group string) groupReader
+
+	mu      sync.Mutex
+	closers []io.Closer
+}
+
+// NewKafkaBus creates a Kafka bus. The options apply to the consumers created by Subscribe.
+func NewKafkaBus(options ...consumer.Option) *KafkaBus {
+	kProducer := producer.NewKProducer()
+
+	bus := &KafkaBus{
+		publisher: kProducer,
+		closers:   []io.Closer{kProducer.Writer},
+	}
+
+	bus.newSubscriber = func(topic, group string) groupReader {
+		kConsumer := consumer.NewKConsumer(topic, slices.Concat(options, []consumer.Option{consumer.WithGroupId(group)})...)
+
+		bus.mu.Lock()
+		bus.closers = append(bus.closers, kConsumer.Reader)
+		bus.mu.Unlock()
+
+		return kConsumer.Reader
+	}
+
+	return bus
+}
+
+// Publish publishes the value through the producer.
+func (b *KafkaBus) Publish(ctx context.Context, key, topic string, data interface{}) error {
+	return b.publisher.Publish(ctx, key, topic, data)
+}
+
+// Subscribe consumes the topic as a member of the consumer group until the context is done or reading fails.
+// Messages are committed once handled, a returned error or panic being logged. The reader is closed on return.
+func (b *KafkaBus) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	reader := b.newSubscriber(topic, group)
+	defer b.release(reader)
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			return fmt.Errorf("failed to read topic %s: %w", topic, err)
+		}
+
+		if err = handleMessage(ctx, msg, handler); err != nil {
+			log.Printf("Error while handling message: topic=%s partition=%d offset=%d: %v",
+				msg.Topic, msg.Partition, msg.Offset, err)
+		}
+
+		if err = reader.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to commit topic %s: %w", topic, err)
+		}
+	}
+}
+
+// handleMessage passes the message to the handler, converting a panic into an error.
+func handleMessage(ctx context.Context, msg kafka.Message, handler Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while handling message: %v\nStack: %s", r, string(debug.Stack()))
+		}
+	}()
+
+	return handler(ctx, fromKafka(msg))
+}
+
+// release closes the reader of a finished subscription and forgets it.
+func (b *KafkaBus) release(reader groupReader) {
+	closer, ok := reader.(io.Closer)
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	b.closers = slices.DeleteFunc(b.closers, func(c io.Closer) bool { return c == closer })
+	b.mu.Unlock()
+
+	if err := closer.Close(); err != nil {
+		log.Printf("Error while closing reader: %v", err)
+	}
+}
+
+// Close closes the producer and the consumers.
+func (b *KafkaBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var errs []error
+	for _, closer := range b.closers {
+		errs = append(errs, closer.Close())
+	}
+	b.closers = nil
+
+	return errors.Join(errs...)
+}
+
+// fromKafka converts a Kafka message.
+func fromKafka(msg kafka.Message) *Message {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, header := range msg.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+
+	return &Message{
+		Topic:   msg.Topic,
+		Key:     string(msg.Key),
+		Value:   msg.Value,
+		Headers: headers,
+		Time:    msg.Time,
+	}
+}
@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/kafkatest"
+	"github.com/segmentio/kafka-go"
+	"testing"
+	"time"
+)
+
+// TestKafkaBus is a function to test publishing and subscribing through the KafkaBus.
+func TestKafkaBus(t *testing.T) {
+	broker := kafkatest.NewBroker()
+	bus := &KafkaBus{
+		publisher: broker.Publisher(),
+		newSubscriber: func(topic, group string) groupReader {
+			return broker.Subscriber(topic, group)
+		},
+	}
+	defer bus.Close()
+
+	// Published before subscribing, delivered since groups start at the first offset.
+	if err := bus.Publish(context.Background(), "john", "events", map[string]string{"name": "john"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	received := make(chan *Message, 2)
+	for _, group := range []string{"mailer", "audit"} {
+		go func() {
+			_ = bus.Subscribe(ctx, "events", group, func(_ context.Context, msg *Message) error {
+				received <- msg
+				return nil
+			})
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			var value map[string]string
+			if err := msg.Decode(&value); err != nil || value["name"] != "john" || msg.Key != "john" {
+				t.Errorf("Subscribe failed: unexpected message %+v (err=%v)", msg, err)
+			}
+			if msg.Headers[constants.KafkaHeaderEventId] == "" {
+				t.Errorf("Subscribe failed: expected event id header but got %v", msg.Headers)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Subscribe failed: expected a message for each group")
+		}
+	}
+}
+
+// brokenReader is a groupReader failing every read.
+type brokenReader struct {
+	closed bool
+}
+
+func (r *brokenReader) FetchMessage(context.Context) (kafka.Message, error) {
+	return kafka.Message{}, errors.New("broker unavailable")
+}
+
+func (r *brokenReader) CommitMessages(context.Context, ...kafka.Message) error {
+	return nil
+}
+
+func (r *brokenReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+// TestKafkaBusSubscribeError is a function to test that Subscribe returns the read error and closes its reader.
+func TestKafkaBusSubscribeError(t *testing.T) {
+	reader := &brokenReader{}
+	bus := &KafkaBus{publisher: kafkatest.NewBroker().Publisher()}
+	bus.newSubscriber = func(topic, group string) groupReader {
+		bus.closers = append(bus.closers, reader)
+		return reader
+	}
+
+	err := bus.Subscribe(context.Background(), "events", "mailer", func(context.Context, *Message) error {
+		return nil
+	})
+	if err == nil || err.Error() != "failed to read topic events: broker unavailable" {
+		t.Errorf("Subscribe failed: expected the read error but got %v", err)
+	}
+
+	if !reader.closed {
+		t.Errorf("Subscribe failed: expected the reader closed")
+	}
+	if len(bus.closers) != 0 {
+		t.Errorf("Subscribe failed: expected no closers left but got %v", bus.closers)
+	}
+}
@@ -0,0 +1,161 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"log"
+	"sync"
+	"time"
+)
+
+// MemoryBus is an in-process Bus backed by channels. A message is delivered once to every group
+// subscribed to its topic when it is published; nothing is persisted.
+type MemoryBus struct {
+	bufferSize int
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	groups map[string]map[string]*memoryGroup
+}
+
+// memoryGroup is the channel shared by the subscribers of a group, removed with its last subscriber.
+type memoryGroup struct {
+	ch      chan *Message
+	left    chan struct{}
+	members int
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		bufferSize: config.GetInt("MESSAGING_MEMORY_BUFFER_SIZE", 1000),
+		done:       make(chan struct{}),
+		groups:     make(map[string]map[string]*memoryGroup),
+	}
+}
+
+// Publish delivers the value to every group subscribed to the topic, waiting while a group buffer is full
+// and the group still has subscribers.
+func (b *MemoryBus) Publish(ctx context.Context, key, topic string, data interface{}) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	msg := &Message{
+		Topic:   topic,
+		Key:     key,
+		Value:   value,
+		Headers: map[string]string{constants.KafkaHeaderEventId: uuid.NewString()},
+		Time:    time.Now(),
+	}
+
+	// Send without holding the lock, so that a slow group does not block Subscribe and Close.
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	groups := make([]*memoryGroup, 0, len(b.groups[topic]))
+	for _, group := range b.groups[topic] {
+		groups = append(groups, group)
+	}
+	b.mu.RUnlock()
+
+	for _, group := range groups {
+		select {
+		case group.ch <- msg:
+		case <-group.left:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			return ErrClosed
+		}
+	}
+
+	return nil
+}
+
+// Subscribe delivers the messages of the topic to the handler until the context is done or the bus is closed.
+// The group stops receiving messages once its last subscriber returned.
+func (b *MemoryBus) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	ch, err := b.join(topic, group)
+	if err != nil {
+		return err
+	}
+	defer b.leave(topic, group)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			return ErrClosed
+		case msg := <-ch:
+			if err = handler(ctx, msg); err != nil {
+				log.Printf("Error while handling message: topic=%s key=%s: %v", msg.Topic, msg.Key, err)
+			}
+		}
+	}
+}
+
+// Close stops the subscribers and rejects further publishing.
+func (b *MemoryBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	return nil
+}
+
+// join returns the channel shared by the members of the group, creating it if needed.
+func (b *MemoryBus) join(topic, group string) (chan *Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	groups, ok := b.groups[topic]
+	if !ok {
+		groups = make(map[string]*memoryGroup)
+		b.groups[topic] = groups
+	}
+
+	member, ok := groups[group]
+	if !ok {
+		member = &memoryGroup{ch: make(chan *Message, b.bufferSize), left: make(chan struct{})}
+		groups[group] = member
+	}
+	member.members++
+	return member.ch, nil
+}
+
+// leave removes a member of the group, dropping the group and its pending messages with the last one.
+func (b *MemoryBus) leave(topic, group string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	member, ok := b.groups[topic][group]
+	if !ok {
+		return
+	}
+
+	if member.members--; member.members > 0 {
+		return
+	}
+
+	close(member.left)
+	delete(b.groups[topic], group)
+	if len(b.groups[topic]) == 0 {
+		delete(b.groups, topic)
+	}
+}
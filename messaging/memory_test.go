@@ -0,0 +1,181 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"testing"
+	"time"
+)
+
+// TestMemoryBus is a function to test publishing and subscribing through the MemoryBus.
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+	defer bus.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	mailer := make(chan domain.ResetPassword, 2)
+	audit := make(chan string, 2)
+
+	subscribe := func(group string, handler Handler) {
+		go func() {
+			_ = bus.Subscribe(ctx, constants.TopicResetPassword, group, handler)
+		}()
+	}
+
+	subscribe("mailer", func(_ context.Context, msg *Message) error {
+		var resetPassword domain.ResetPassword
+		if err := msg.Decode(&resetPassword); err != nil {
+			return err
+		}
+		mailer <- resetPassword
+		return nil
+	})
+	subscribe("audit", func(_ context.Context, msg *Message) error {
+		audit <- msg.Key
+		return nil
+	})
+
+	// Wait until both groups are registered.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		bus.mu.RLock()
+		ready := len(bus.groups[constants.TopicResetPassword]) == 2
+		bus.mu.RUnlock()
+		if ready || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	expected := domain.ResetPassword{Email: "john@example.com", Token: "token"}
+	if err := bus.Publish(ctx, "john", constants.TopicResetPassword, expected); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case actual := <-mailer:
+		if actual != expected {
+			t.Errorf("Subscribe failed: expected %+v but got %+v", expected, actual)
+		}
+	case <-ctx.Done():
+		t.Fatal("Subscribe failed: mailer group timed out")
+	}
+
+	select {
+	case key := <-audit:
+		if key != "john" {
+			t.Errorf("Subscribe failed: expected key %v but got %v", "john", key)
+		}
+	case <-ctx.Done():
+		t.Fatal("Subscribe failed: audit group timed out")
+	}
+
+	_ = bus.Close()
+	if err := bus.Publish(ctx, "john", constants.TopicResetPassword, expected); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish failed: expected ErrClosed but got %v", err)
+	}
+}
+
+// TestMemoryBusCloseWhilePublishing is a function to test that a full group does not block Close.
+func TestMemoryBusCloseWhilePublishing(t *testing.T) {
+	bus := NewMemoryBus()
+	bus.bufferSize = 1
+
+	// A group without running subscriber, whose buffer fills up after one message.
+	if _, err := bus.join("events", "slow"); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	_ = bus.Publish(context.Background(), "1", "events", "first")
+
+	published := make(chan error, 1)
+	go func() {
+		published <- bus.Publish(context.Background(), "2", "events", "second")
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		_ = bus.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close failed: blocked by a full group")
+	}
+
+	select {
+	case err := <-published:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Publish failed: expected ErrClosed but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish failed: still blocked after Close")
+	}
+}
+
+// TestMemoryBusUnsubscribe is a function to test that a group without subscribers does not block Publish.
+func TestMemoryBusUnsubscribe(t *testing.T) {
+	bus := NewMemoryBus()
+	bus.bufferSize = 1
+	defer bus.Close()
+
+	// A subscriber leaving while Publish waits on its full buffer.
+	if _, err := bus.join("events", "slow"); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	_ = bus.Publish(context.Background(), "1", "events", "first")
+
+	published := make(chan error, 1)
+	go func() {
+		published <- bus.Publish(context.Background(), "2", "events", "second")
+	}()
+
+	bus.leave("events", "slow")
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Errorf("Publish failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish failed: blocked by a group without subscribers")
+	}
+
+	// A subscriber returning on cancellation.
+	ctx, cancel := context.WithCancel(context.Background())
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- bus.Subscribe(ctx, "events", "audit", func(context.Context, *Message) error { return nil })
+	}()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		bus.mu.RLock()
+		ready := len(bus.groups["events"]) == 1
+		bus.mu.RUnlock()
+		if ready || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	cancel()
+	if err := <-subscribed; !errors.Is(err, context.Canceled) {
+		t.Errorf("Subscribe failed: expected %v but got %v", context.Canceled, err)
+	}
+
+	bus.mu.RLock()
+	groups := len(bus.groups["events"])
+	bus.mu.RUnlock()
+	if groups != 0 {
+		t.Errorf("Subscribe failed: expected no group left but got %d", groups)
+	}
+
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), time.Second)
+	defer cancelTimeout()
+	for i := 0; i < 3; i++ {
+		if err := bus.Publish(timeout, "3", "events", "third"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+}
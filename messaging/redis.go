@@ -0,0 +1,259 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	fieldKey     = "key"
+	fieldValue   = "value"
+	fieldHeaders = "headers"
+)
+
+// streams are the Redis Streams commands used by RedisBus.
+type streams interface {
+	add(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error
+	createGroup(ctx context.Context, stream, group, start string) error
+	read(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]redis.XMessage, error)
+	claim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]redis.XMessage, error)
+	ack(ctx context.Context, stream, group, id string) error
+}
+
+// RedisBus is a Bus backed by Redis Streams, sharing the connection of the cache.
+// Each topic is a stream and each group a Redis consumer group. Messages left pending by a failed handler
+// or a crashed member are claimed again once idle for MESSAGING_REDIS_CLAIM_MIN_IDLE_MS.
+type RedisBus struct {
+	streams       streams
+	maxLen        int64
+	consumer      string
+	startId       string
+	claimInterval time.Duration
+	claimMinIdle  time.Duration
+}
+
+func NewRedisBus(redisCache *cache.RedisCache) *RedisBus {
+	return newRedisBus(&redisStreams{client: redisCache.Client()})
+}
+
+func newRedisBus(streams streams) *RedisBus {
+	return &RedisBus{
+		streams:       streams,
+		maxLen:        int64(config.GetInt("MESSAGING_REDIS_MAX_LEN", 100000)),
+		consumer:      config.GetString("MESSAGING_REDIS_CONSUMER", defaultConsumer()),
+		startId:       config.GetString("MESSAGING_REDIS_START_ID", "0"),
+		claimInterval: time.Duration(config.GetInt("MESSAGING_REDIS_CLAIM_INTERVAL_MS", 30000)) * time.Millisecond,
+		claimMinIdle:  time.Duration(config.GetInt("MESSAGING_REDIS_CLAIM_MIN_IDLE_MS", 60000)) * time.Millisecond,
+	}
+}
+
+// defaultConsumer returns the host name, stable across restarts of the same instance.
+func defaultConsumer() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return uuid.NewString()
+}
+
+// Publish appends the value to the stream of the topic, trimming it to approximately MESSAGING_REDIS_MAX_LEN entries.
+func (b *RedisBus) Publish(ctx context.Context, key, topic string, data interface{}) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	headers, err := json.Marshal(map[string]string{constants.KafkaHeaderEventId: uuid.NewString()})
+	if err != nil {
+		return err
+	}
+
+	err = b.streams.add(ctx, topic, b.maxLen, map[string]interface{}{
+		fieldKey:     key,
+		fieldValue:   value,
+		fieldHeaders: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish message to stream %s: %w", topic, err)
+	}
+
+	return nil
+}
+
+// Subscribe reads the stream of the topic as a member of the group until the context is done.
+// New groups start at MESSAGING_REDIS_START_ID, the beginning of the stream by default like the Kafka backend.
+// Messages are acknowledged once the handler succeeds, and claimed again every MESSAGING_REDIS_CLAIM_INTERVAL_MS otherwise.
+func (b *RedisBus) Subscribe(ctx context.Context, topic, group string, handler Handler) error {
+	err := b.streams.createGroup(ctx, topic, group, b.startId)
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on stream %s: %w", group, topic, err)
+	}
+
+	// Claim right away the messages left pending before a restart.
+	nextClaim := time.Now()
+	for {
+		if !time.Now().Before(nextClaim) {
+			if err = b.claim(ctx, topic, group, handler); err != nil && ctx.Err() == nil {
+				log.Printf("failed to claim pending messages: stream=%s group=%s: %v", topic, group, err)
+			}
+			nextClaim = time.Now().Add(b.claimInterval)
+		}
+
+		entries, err := b.streams.read(ctx, topic, group, b.consumer, 10, time.Second)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read stream %s: %w", topic, err)
+		}
+
+		for _, entry := range entries {
+			b.handle(ctx, topic, group, entry, handler)
+		}
+	}
+}
+
+// claim handles the messages of the group pending for longer than the minimum idle time.
+func (b *RedisBus) claim(ctx context.Context, topic, group string, handler Handler) error {
+	entries, err := b.streams.claim(ctx, topic, group, b.consumer, b.claimMinIdle, 100)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		b.handle(ctx, topic, group, entry, handler)
+	}
+	return nil
+}
+
+// handle passes the entry to the handler and acknowledges it on success. A panicking handler leaves it pending.
+func (b *RedisBus) handle(ctx context.Context, topic, group string, entry redis.XMessage, handler Handler) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic while handling message: %v\nStack: %s", r, string(debug.Stack()))
+			}
+		}()
+		return handler(ctx, fromStream(topic, entry))
+	}()
+
+	if err != nil {
+		log.Printf("Error while handling message: stream=%s id=%s: %v", topic, entry.ID, err)
+		return
+	}
+
+	if err = b.streams.ack(ctx, topic, group, entry.ID); err != nil {
+		log.Printf("failed to acknowledge message: stream=%s id=%s: %v", topic, entry.ID, err)
+	}
+}
+
+// Close is a no-op, the connection belongs to the cache.
+func (b *RedisBus) Close() error {
+	return nil
+}
+
+// fromStream converts a stream entry.
+func fromStream(topic string, entry redis.XMessage) *Message {
+	msg := &Message{
+		Topic:   topic,
+		Headers: make(map[string]string),
+	}
+
+	if key, ok := entry.Values[fieldKey].(string); ok {
+		msg.Key = key
+	}
+
+	if value, ok := entry.Values[fieldValue].(string); ok {
+		msg.Value = []byte(value)
+	}
+
+	if headers, ok := entry.Values[fieldHeaders].(string); ok {
+		_ = json.Unmarshal([]byte(headers), &msg.Headers)
+	}
+
+	// Stream IDs start with the millisecond timestamp of the entry.
+	var millis int64
+	if _, err := fmt.Sscanf(entry.ID, "%d-", &millis); err == nil {
+		msg.Time = time.UnixMilli(millis)
+	}
+
+	return msg
+}
+
+// redisStreams runs the stream commands on a Redis client.
+type redisStreams struct {
+	client *redis.Client
+}
+
+func (s *redisStreams) add(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error {
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+func (s *redisStreams) createGroup(ctx context.Context, stream, group, start string) error {
+	return s.client.XGroupCreateMkStream(ctx, stream, group, start).Err()
+}
+
+func (s *redisStreams) read(ctx context.Context, stream, group, consumer string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	result, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []redis.XMessage
+	for _, s := range result {
+		entries = append(entries, s.Messages...)
+	}
+	return entries, nil
+}
+
+// claim claims the idle pending entries of every member, following the XAUTOCLAIM cursor up to count entries.
+func (s *redisStreams) claim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, count int64) ([]redis.XMessage, error) {
+	var entries []redis.XMessage
+	for start := "0-0"; ; {
+		claimed, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    group,
+			Consumer: consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    count,
+		}).Result()
+		if err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, claimed...)
+		if next == "0-0" || int64(len(entries)) >= count {
+			return entries, nil
+		}
+		start = next
+	}
+}
+
+func (s *redisStreams) ack(ctx context.Context, stream, group, id string) error {
+	return s.client.XAck(ctx, stream, group, id).Err()
+}
@@ -0,0 +1,176 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStreams simulates Redis Streams consumer groups with their pending entries lists.
+type memoryStreams struct {
+	mu      sync.Mutex
+	entries map[string][]redis.XMessage
+	groups  map[string]*streamGroup
+}
+
+type streamGroup struct {
+	delivered int
+	pending   map[string]time.Time
+}
+
+func newMemoryStreams() *memoryStreams {
+	return &memoryStreams{
+		entries: make(map[string][]redis.XMessage),
+		groups:  make(map[string]*streamGroup),
+	}
+}
+
+func (s *memoryStreams) add(_ context.Context, stream string, _ int64, values map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Redis returns the values as strings.
+	converted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if bytes, ok := value.([]byte); ok {
+			value = string(bytes)
+		}
+		converted[key] = value
+	}
+
+	id := fmt.Sprintf("%d-%d", time.Now().UnixMilli(), len(s.entries[stream]))
+	s.entries[stream] = append(s.entries[stream], redis.XMessage{ID: id, Values: converted})
+	return nil
+}
+
+func (s *memoryStreams) createGroup(_ context.Context, stream, group, start string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[stream+"/"+group]; ok {
+		return errors.New("BUSYGROUP Consumer Group name already exists")
+	}
+
+	delivered := len(s.entries[stream])
+	if start == "0" {
+		delivered = 0
+	}
+	s.groups[stream+"/"+group] = &streamGroup{delivered: delivered, pending: make(map[string]time.Time)}
+	return nil
+}
+
+func (s *memoryStreams) read(ctx context.Context, stream, group, _ string, count int64, _ time.Duration) ([]redis.XMessage, error) {
+	s.mu.Lock()
+	g := s.groups[stream+"/"+group]
+	entries := s.entries[stream][g.delivered:min(len(s.entries[stream]), g.delivered+int(count))]
+	g.delivered += len(entries)
+	for _, entry := range entries {
+		g.pending[entry.ID] = time.Now()
+	}
+	s.mu.Unlock()
+
+	if len(entries) == 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Millisecond):
+		}
+		return nil, redis.Nil
+	}
+	return entries, nil
+}
+
+func (s *memoryStreams) claim(_ context.Context, stream, group, _ string, minIdle time.Duration, _ int64) ([]redis.XMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.groups[stream+"/"+group]
+
+	var claimed []redis.XMessage
+	for _, entry := range s.entries[stream] {
+		if deliveredAt, ok := g.pending[entry.ID]; ok && time.Since(deliveredAt) >= minIdle {
+			g.pending[entry.ID] = time.Now()
+			claimed = append(claimed, entry)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memoryStreams) ack(_ context.Context, stream, group, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.groups[stream+"/"+group].pending, id)
+	return nil
+}
+
+func (s *memoryStreams) pending(stream, group string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.groups[stream+"/"+group].pending)
+}
+
+// TestRedisBusRedelivery is a function to test that failed and panicking messages are claimed again.
+func TestRedisBusRedelivery(t *testing.T) {
+	streams := newMemoryStreams()
+	bus := newRedisBus(streams)
+	bus.claimInterval = 10 * time.Millisecond
+	bus.claimMinIdle = 0
+
+	// Published before the group exists, delivered since new groups start at the beginning of the stream.
+	for _, key := range []string{"fail", "panic", "ok"} {
+		if err := bus.Publish(context.Background(), key, "events", key); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		_ = bus.Subscribe(ctx, "events", "audit", func(_ context.Context, msg *Message) error {
+			mu.Lock()
+			attempts[msg.Key]++
+			attempt := attempts[msg.Key]
+			if attempts["fail"] >= 2 && attempts["panic"] >= 2 && attempts["ok"] >= 1 {
+				once.Do(func() { close(done) })
+			}
+			mu.Unlock()
+
+			switch {
+			case msg.Key == "fail" && attempt == 1:
+				return errors.New("temporary failure")
+			case msg.Key == "panic" && attempt == 1:
+				panic("handler bug")
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatalf("Subscribe failed: expected redelivery but got attempts %v", attempts)
+	}
+
+	// Let the last message be acknowledged.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["ok"] != 1 {
+		t.Errorf("Subscribe failed: expected 1 attempt of acknowledged message but got %d", attempts["ok"])
+	}
+	if pending := streams.pending("events", "audit"); pending != 0 {
+		t.Errorf("Subscribe failed: expected no pending message but got %d", pending)
+	}
+}
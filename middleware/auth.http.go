@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"github.com/ngdangkietswe/swe-go-common-shared/security"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"net/http"
+)
+
+// ReasonAccessDenied is the reason of the problem returned by RequirePermission.
+const ReasonAccessDenied = "ACCESS_DENIED"
+
+// Problem is the RFC 7807 body of the HTTP auth failures.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HTTP returns the net/http middleware authenticating requests like Unary does calls. The principal is stored
+// under the same context accessor, see grpcutil.PrincipalFrom, and the PublicMethods are matched against the
+// URL path, e.g. "/healthz" or "/webhooks/*".
+func (a *AuthInterceptor) HTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md := metadata.MD{}
+		for key, values := range r.Header {
+			md.Set(key, values...)
+		}
+
+		ctx, err := a.authenticate(r.Context(), md, r.URL.Path)
+		if err != nil {
+			writeStatusProblem(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequirePermission returns a net/http middleware letting through the principals granted the permission,
+// as SecuredAuth does for gRPC. It must be placed after AuthInterceptor.HTTP.
+func RequirePermission(resource, action string) func(http.Handler) http.Handler {
+	permission := domain.Permission{Resource: resource, Action: action}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := grpcutil.PrincipalFrom(r.Context())
+			if !ok {
+				WriteProblem(w, http.StatusUnauthorized, ReasonMissingCredentials, "missing credentials")
+				return
+			}
+
+			if !security.IsGranted(principal, permission) {
+				WriteProblem(w, http.StatusForbidden, ReasonAccessDenied, "access denied")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteProblem writes an application/problem+json response.
+func WriteProblem(w http.ResponseWriter, statusCode int, reason, detail string) {
+	if statusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", constants.TokenPrefix)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(statusCode)
+
+	err := json.NewEncoder(w).Encode(&Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+		Reason: reason,
+	})
+	if err != nil {
+		log.Printf("failed to write problem response: %v", err)
+	}
+}
+
+// writeStatusProblem writes the problem of a status error returned by authenticate.
+func writeStatusProblem(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+
+	var reason string
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			reason = info.Reason
+		}
+	}
+
+	WriteProblem(w, httpStatus(st.Code()), reason, st.Message())
+}
+
+// httpStatus maps the status codes of authenticate to HTTP status codes.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	shareddomain "github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/util"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAuthInterceptorHTTP is a function to test the net/http auth middleware and RequirePermission.
+func TestAuthInterceptorHTTP(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+
+	token, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false)
+	claims, _ := util.ParseToken(token, testSecret)
+	userPermission := &shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "invoice", Action: "READ"}}}
+	payload, signature, _ := util.SignUserPermission(userPermission, claims, testSecret)
+
+	scoped, _ := util.GenerateServiceToken("billing-job", []string{"invoice:read"})
+
+	interceptor := NewAuthInterceptor(AuthOptions{PublicMethods: []string{"/healthz"}})
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	mux.Handle("/invoices", RequirePermission("invoice", "READ")(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})))
+	handler := interceptor.HTTP(mux)
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+		reason  string
+	}{
+		{"public path", "/healthz", nil, http.StatusOK, ""},
+		{"missing credentials", "/invoices", nil, http.StatusUnauthorized, ReasonMissingCredentials},
+		{"invalid token", "/invoices", map[string]string{"Authorization": "Bearer invalid"}, http.StatusUnauthorized, ReasonInvalidToken},
+		{"user without permission", "/invoices", map[string]string{"Authorization": "Bearer " + token}, http.StatusForbidden, ReasonAccessDenied},
+		{"user with permission", "/invoices", map[string]string{
+			"Authorization":                  "Bearer " + token,
+			"Grpc-User-Permission":           payload,
+			"Grpc-User-Permission-Signature": signature,
+		}, http.StatusOK, ""},
+		{"service with scope", "/invoices", map[string]string{"Authorization": "Bearer " + scoped}, http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		for key, value := range test.headers {
+			request.Header.Set(key, value)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.status {
+			t.Errorf("HTTP failed for %s: expected status %d but got %d", test.name, test.status, recorder.Code)
+			continue
+		}
+		if test.reason == "" {
+			continue
+		}
+
+		var problem Problem
+		if err := json.NewDecoder(recorder.Body).Decode(&problem); err != nil || problem.Reason != test.reason || problem.Status != test.status {
+			t.Errorf("HTTP failed for %s: expected reason %s but got %+v (err=%v)", test.name, test.reason, problem, err)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			t.Errorf("HTTP failed for %s: expected problem content type but got %s", test.name, contentType)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	grpcdomain "github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"github.com/ngdangkietswe/swe-go-common-shared/util"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"strings"
+	"sync"
+)
+
+// AuthErrorDomain is the domain of the errdetails.ErrorInfo attached to auth failures.
+const AuthErrorDomain = "swe-go-common-shared"
+
+// Reasons of the errdetails.ErrorInfo attached to auth failures.
+const (
+	ReasonMissingCredentials = "MISSING_CREDENTIALS"
+	ReasonInvalidToken       = "INVALID_TOKEN"
+	ReasonTokenExpired       = "TOKEN_EXPIRED"
+	ReasonTokenNotValidYet   = "TOKEN_NOT_VALID_YET"
+	ReasonInvalidIssuer      = "INVALID_ISSUER"
+	ReasonInvalidAudience    = "INVALID_AUDIENCE"
+	ReasonInvalidTokenType   = "INVALID_TOKEN_TYPE"
+	ReasonTokenRevoked       = "TOKEN_REVOKED"
+	ReasonInvalidPermissions = "INVALID_PERMISSIONS"
+)
+
+// RevocationChecker reports whether a token was revoked, by its jti or its refresh-token family ID (the fid claim,
+// empty if missing). token.Service satisfies it.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti, familyId string) (bool, error)
+}
+
+var (
+	revocationMu      sync.RWMutex
+	defaultRevocation RevocationChecker
+)
+
+// SetDefaultRevocation sets the RevocationChecker of the interceptors created without one, including the deprecated
+// AuthMiddleware. Services revoking tokens set it at startup, starting token.Service.Run so that most checks are
+// answered by its bloom filter rather than the store.
+func SetDefaultRevocation(revocation RevocationChecker) {
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+	defaultRevocation = revocation
+}
+
+// AuthOptions configures the unary and stream auth interceptors.
+type AuthOptions struct {
+	// PublicMethods are the full method names ("/package.Service/Method") callable without credentials.
+	// A trailing "*" matches every method of a service ("/package.Service/*").
+	PublicMethods []string
+
+	// AllowAnonymous lets calls without credentials through to protected methods, as AuthMiddleware did.
+	AllowAnonymous bool
+
+	// Secret verifies the token signature. Defaults to the JWT_SECRET config key.
+	Secret string
+
+	// Keyfunc verifies the token signature instead of Secret, e.g. jwks.Loader.Keyfunc for asymmetric tokens.
+	Keyfunc jwt.Keyfunc
+
+	// Validation sets the issuer, audience and leeway checks. Defaults to util.DefaultValidationOptions.
+	// Only access tokens are accepted, whatever its TokenType.
+	Validation *util.ValidationOptions
+
+	// Revocation rejects revoked tokens. Defaults to the checker of SetDefaultRevocation, nil skips the check.
+	Revocation RevocationChecker
+
+	// PermissionSecret verifies the signature of the grpc-user-permission metadata, see util.SignUserPermission.
+	// Defaults to the PERMISSION_SECRET config key, then to Secret.
+	PermissionSecret string
+
+	// Permissions loads the permissions of the user when the metadata is missing or not verified.
+	// Nil leaves the principal without permissions.
+	Permissions PermissionLoader
+}
+
+// AuthInterceptor authenticates incoming calls and stores their principal in the context.
+type AuthInterceptor struct {
+	options        AuthOptions
+	publicMethods  map[string]bool
+	publicPrefixes []string
+}
+
+// NewAuthInterceptor creates the interceptors of the given options.
+func NewAuthInterceptor(options AuthOptions) *AuthInterceptor {
+	if options.Secret == "" && options.Keyfunc == nil {
+		options.Secret = config.GetString("JWT_SECRET", "")
+	}
+
+	if options.Keyfunc == nil {
+		options.Keyfunc = util.HMACKeyfunc(options.Secret)
+	}
+
+	if options.PermissionSecret == "" {
+		options.PermissionSecret = config.GetString("PERMISSION_SECRET", options.Secret)
+	}
+
+	if options.Revocation == nil {
+		revocationMu.RLock()
+		options.Revocation = defaultRevocation
+		revocationMu.RUnlock()
+	}
+
+	validation := util.DefaultValidationOptions()
+	if options.Validation != nil {
+		validation = *options.Validation
+	}
+	validation.TokenType = constants.TokenTypeAccess
+	options.Validation = &validation
+
+	interceptor := &AuthInterceptor{
+		options:       options,
+		publicMethods: make(map[string]bool),
+	}
+
+	for _, method := range options.PublicMethods {
+		if prefix, ok := strings.CutSuffix(method, "*"); ok {
+			interceptor.publicPrefixes = append(interceptor.publicPrefixes, prefix)
+		} else {
+			interceptor.publicMethods[method] = true
+		}
+	}
+
+	return interceptor
+}
+
+// Unary returns the unary server interceptor.
+func (a *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx, err := a.authenticate(ctx, md, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the stream server interceptor.
+func (a *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		ctx, err := a.authenticate(ss.Context(), md, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// IsPublic reports whether the method is callable without credentials.
+func (a *AuthInterceptor) IsPublic(fullMethod string) bool {
+	if a.publicMethods[fullMethod] {
+		return true
+	}
+	for _, prefix := range a.publicPrefixes {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the context with the principal of the call. Public methods are called anonymously
+// when the credentials are missing or invalid.
+func (a *AuthInterceptor) authenticate(ctx context.Context, md metadata.MD, fullMethod string) (context.Context, error) {
+	public := a.IsPublic(fullMethod)
+
+	tokens := md.Get(strings.ToLower(constants.AuthorizationHeader))
+	if len(tokens) == 0 || tokens[0] == "" {
+		if public || a.options.AllowAnonymous {
+			return ctx, nil
+		}
+		return nil, authError(codes.Unauthenticated, ReasonMissingCredentials, fullMethod, "missing credentials")
+	}
+
+	principal, err := a.principal(ctx, tokens[0], md, fullMethod)
+	if err != nil {
+		if public {
+			return ctx, nil
+		}
+		return nil, err
+	}
+
+	return grpcutil.WithPrincipal(ctx, principal), nil
+}
+
+// principal verifies the bearer token and builds the principal with its permissions.
+func (a *AuthInterceptor) principal(ctx context.Context, value string, md metadata.MD, fullMethod string) (*grpcdomain.SweGrpcPrincipal, error) {
+	token, ok := strings.CutPrefix(value, constants.TokenPrefix)
+	if !ok {
+		return nil, authError(codes.Unauthenticated, ReasonInvalidToken, fullMethod, "invalid authorization scheme")
+	}
+
+	jwtClaims, err := util.ValidateToken(strings.TrimSpace(token), a.options.Keyfunc, *a.options.Validation)
+	if err != nil {
+		return nil, authError(codes.Unauthenticated, tokenErrorReason(err), fullMethod, "invalid token")
+	}
+
+	if a.options.Revocation != nil {
+		jti, _ := (*jwtClaims)["jti"].(string)
+		familyId, _ := (*jwtClaims)[constants.ClaimFamilyId].(string)
+		revoked, err := a.options.Revocation.IsRevoked(ctx, jti, familyId)
+		if err != nil {
+			return nil, status.Error(codes.Unavailable, "failed to check token revocation")
+		}
+		if revoked {
+			return nil, authError(codes.Unauthenticated, ReasonTokenRevoked, fullMethod, "token revoked")
+		}
+	}
+
+	principal, err := grpcutil.AsGrpcPrincipal(jwtClaims)
+	if err != nil {
+		return nil, authError(codes.Unauthenticated, ReasonInvalidToken, fullMethod, "invalid token")
+	}
+	principal.Token = strings.TrimSpace(token)
+
+	// Services are authorized by the scopes of their token, they have no user permissions.
+	if principal.IsService() {
+		return principal, nil
+	}
+
+	userPermission, err := a.permissions(ctx, principal, jwtClaims, md, fullMethod)
+	if err != nil {
+		return nil, err
+	}
+	principal.UserPermission = userPermission
+
+	return principal, nil
+}
+
+// permissions returns the propagated permissions of the principal once verified, either by their signature or by the
+// ph claim of the token, and loads them otherwise. Permissions not matching the ph claim are only denied without loader.
+func (a *AuthInterceptor) permissions(ctx context.Context, principal *grpcdomain.SweGrpcPrincipal, jwtClaims *jwt.MapClaims, md metadata.MD, fullMethod string) (*domain.UserPermission, error) {
+	if payloads := md.Get(constants.GrpcMetadataUserPermission); len(payloads) > 0 {
+		var userPermission *domain.UserPermission
+		if err := json.Unmarshal([]byte(payloads[0]), &userPermission); err != nil {
+			log.Printf("invalid user permissions of user %s: %v", principal.UserId, err)
+		} else if signatures := md.Get(constants.GrpcMetadataUserPermissionSignature); len(signatures) > 0 &&
+			util.VerifyUserPermission(payloads[0], signatures[0], jwtClaims, a.options.PermissionSecret) {
+			return userPermission, nil
+		} else if principal.PermissionsHash != "" {
+			// A token bound to its permissions only accepts the permissions it was issued with. Permissions changed
+			// since then are loaded afresh when possible.
+			if principal.PermissionsHash == util.PermissionsHash(userPermission) {
+				return userPermission, nil
+			}
+			if a.options.Permissions == nil {
+				return nil, authError(codes.PermissionDenied, ReasonInvalidPermissions, fullMethod, "user permissions do not match token")
+			}
+			log.Printf("user permissions of user %s do not match token, loading them", principal.UserId)
+		} else {
+			log.Printf("unverified user permissions of user %s", principal.UserId)
+		}
+	}
+
+	if a.options.Permissions == nil {
+		return nil, nil
+	}
+
+	userPermission, err := a.options.Permissions.LoadPermissions(ctx, principal.UserId)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, "failed to load user permissions")
+	}
+
+	return userPermission, nil
+}
+
+// tokenErrorReason returns the reason of a token validation error.
+func tokenErrorReason(err error) string {
+	switch {
+	case errors.Is(err, util.ErrTokenExpired):
+		return ReasonTokenExpired
+	case errors.Is(err, util.ErrTokenNotValidYet):
+		return ReasonTokenNotValidYet
+	case errors.Is(err, util.ErrTokenInvalidIssuer):
+		return ReasonInvalidIssuer
+	case errors.Is(err, util.ErrTokenInvalidAudience):
+		return ReasonInvalidAudience
+	case errors.Is(err, util.ErrTokenInvalidType):
+		return ReasonInvalidTokenType
+	default:
+		return ReasonInvalidToken
+	}
+}
+
+// authError returns a status error with an errdetails.ErrorInfo describing the failure.
+func authError(code codes.Code, reason, fullMethod, message string) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   AuthErrorDomain,
+		Metadata: map[string]string{"method": fullMethod},
+	}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// authenticatedStream overrides the context of a server stream with the authenticated one.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
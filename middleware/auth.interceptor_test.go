@@ -0,0 +1,350 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	shareddomain "github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"github.com/ngdangkietswe/swe-go-common-shared/util"
+	"github.com/spf13/viper"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+const testSecret = "test-secret"
+
+// testStream is a grpc.ServerStream carrying a context.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+// incoming returns an incoming context with the given authorization header, if any.
+func incoming(authorization string) context.Context {
+	md := metadata.MD{}
+	if authorization != "" {
+		md.Set("authorization", authorization)
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// TestAuthInterceptorUnary is a function to test the unary auth interceptor.
+func TestAuthInterceptorUnary(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+	token, err := util.GenerateToken(&domain.GrpcUser{Id: "user-1", Username: "john"}, false)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	refresh, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, true)
+
+	interceptor := NewAuthInterceptor(AuthOptions{PublicMethods: []string{"/auth.AuthService/Login", "/health.Health/*"}})
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		code          codes.Code
+		reason        string
+		userId        string
+	}{
+		{"authenticated", "/user.UserService/Get", "Bearer " + token, codes.OK, "", "user-1"},
+		{"missing credentials", "/user.UserService/Get", "", codes.Unauthenticated, ReasonMissingCredentials, ""},
+		{"invalid token", "/user.UserService/Get", "Bearer invalid", codes.Unauthenticated, ReasonInvalidToken, ""},
+		{"refresh token", "/user.UserService/Get", "Bearer " + refresh, codes.Unauthenticated, ReasonInvalidTokenType, ""},
+		{"invalid scheme", "/user.UserService/Get", "Basic " + token, codes.Unauthenticated, ReasonInvalidToken, ""},
+		{"public method", "/auth.AuthService/Login", "", codes.OK, "", ""},
+		{"public service", "/health.Health/Check", "Bearer invalid", codes.OK, "", ""},
+		{"public method with token", "/auth.AuthService/Login", "Bearer " + token, codes.OK, "", "user-1"},
+	}
+
+	for _, test := range tests {
+		var userId string
+		_, err := interceptor.Unary()(incoming(test.authorization), nil, &grpc.UnaryServerInfo{FullMethod: test.method},
+			func(ctx context.Context, _ any) (any, error) {
+				if principal := grpcutil.GetGrpcPrincipal(ctx); principal != nil {
+					userId = principal.UserId
+				}
+				return nil, nil
+			})
+
+		st := status.Convert(err)
+		if st.Code() != test.code {
+			t.Errorf("Unary failed for %s: expected code %v but got %v", test.name, test.code, st.Code())
+		}
+		if userId != test.userId {
+			t.Errorf("Unary failed for %s: expected principal %q but got %q", test.name, test.userId, userId)
+		}
+
+		if test.reason != "" {
+			details := st.Details()
+			if len(details) != 1 || details[0].(*errdetails.ErrorInfo).Reason != test.reason {
+				t.Errorf("Unary failed for %s: expected reason %s but got %v", test.name, test.reason, details)
+			}
+		}
+	}
+}
+
+// TestAuthInterceptorStream is a function to test the stream auth interceptor.
+func TestAuthInterceptorStream(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+	token, err := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	interceptor := NewAuthInterceptor(AuthOptions{})
+	info := &grpc.StreamServerInfo{FullMethod: "/chat.ChatService/Stream"}
+
+	var userId string
+	err = interceptor.Stream()(nil, &testStream{ctx: incoming("Bearer " + token)}, info, func(_ any, ss grpc.ServerStream) error {
+		userId = grpcutil.GetGrpcPrincipal(ss.Context()).UserId
+		return nil
+	})
+	if err != nil || userId != "user-1" {
+		t.Errorf("Stream failed: expected principal %q but got %q (%v)", "user-1", userId, err)
+	}
+
+	err = interceptor.Stream()(nil, &testStream{ctx: incoming("")}, info, func(any, grpc.ServerStream) error {
+		t.Errorf("Stream failed: handler called without credentials")
+		return nil
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Stream failed: expected code %v but got %v", codes.Unauthenticated, status.Code(err))
+	}
+}
+
+// revoked is a RevocationChecker denying a single jti or family.
+type revoked string
+
+func (r revoked) IsRevoked(_ context.Context, jti, familyId string) (bool, error) {
+	return jti == string(r) || familyId == string(r), nil
+}
+
+// TestAuthInterceptorRevocation is a function to test that revoked tokens and tokens of revoked families are rejected,
+// also by the deprecated AuthMiddleware.
+func TestAuthInterceptorRevocation(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+	byJti, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false, util.WithClaim("jti", "revoked"))
+	byFamily, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false, util.WithClaim(constants.ClaimFamilyId, "revoked"))
+
+	// AuthMiddleware is built once, and picks up the checker set afterwards.
+	if _, err := AuthMiddleware(incoming("Bearer "+byJti), nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"},
+		func(context.Context, any) (any, error) { return nil, nil }); err != nil {
+		t.Fatalf("AuthMiddleware failed: %v", err)
+	}
+
+	SetDefaultRevocation(revoked("revoked"))
+	defer SetDefaultRevocation(nil)
+
+	interceptors := map[string]grpc.UnaryServerInterceptor{
+		"interceptor":    NewAuthInterceptor(AuthOptions{}).Unary(),
+		"AuthMiddleware": AuthMiddleware,
+	}
+
+	for name, interceptor := range interceptors {
+		for _, token := range []string{byJti, byFamily} {
+			_, err := interceptor(incoming("Bearer "+token), nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"},
+				func(context.Context, any) (any, error) {
+					t.Errorf("%s failed: handler called with a revoked token", name)
+					return nil, nil
+				})
+
+			details := status.Convert(err).Details()
+			if len(details) != 1 || details[0].(*errdetails.ErrorInfo).Reason != ReasonTokenRevoked {
+				t.Errorf("%s failed: expected reason %s but got %v", name, ReasonTokenRevoked, err)
+			}
+		}
+	}
+}
+
+// TestAuthInterceptorClaims is a function to test the principal built from the roles, scope and ph claims.
+func TestAuthInterceptorClaims(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+
+	userPermission := &shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "user", Action: "READ"}}}
+	token, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false,
+		util.WithRoles("admin"), util.WithScopes("user:read", "user:write"), util.WithPermissionsHash(userPermission))
+
+	bound, _ := json.Marshal(userPermission)
+	forged, _ := json.Marshal(&shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "user", Action: "DELETE"}}})
+
+	interceptor := NewAuthInterceptor(AuthOptions{})
+
+	tests := []struct {
+		name        string
+		permissions string
+		code        codes.Code
+	}{
+		{"without permissions", "", codes.OK},
+		{"bound permissions", string(bound), codes.OK},
+		{"forged permissions", string(forged), codes.PermissionDenied},
+	}
+
+	for _, test := range tests {
+		ctx := incoming("Bearer " + token)
+		if test.permissions != "" {
+			md, _ := metadata.FromIncomingContext(ctx)
+			md.Set("grpc-user-permission", test.permissions)
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+
+		var principal *domain.SweGrpcPrincipal
+		_, err := interceptor.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"},
+			func(ctx context.Context, _ any) (any, error) {
+				principal = grpcutil.GetGrpcPrincipal(ctx)
+				return nil, nil
+			})
+
+		if code := status.Code(err); code != test.code {
+			t.Errorf("Unary failed for %s: expected code %v but got %v", test.name, test.code, code)
+			continue
+		}
+		if test.code != codes.OK {
+			continue
+		}
+		if len(principal.Roles) != 1 || principal.Roles[0] != "admin" || len(principal.Scopes) != 2 {
+			t.Errorf("Unary failed for %s: unexpected roles %v and scopes %v", test.name, principal.Roles, principal.Scopes)
+		}
+	}
+}
+
+// TestAuthInterceptorPermissions is a function to test the verification of the propagated permissions.
+func TestAuthInterceptorPermissions(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+
+	token, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false)
+	claims, _ := util.ParseToken(token, testSecret)
+
+	signed := &shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "user", Action: "READ"}}}
+	loaded := &shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "user", Action: "WRITE"}}}
+
+	payload, signature, err := util.SignUserPermission(signed, claims, testSecret)
+	if err != nil {
+		t.Fatalf("SignUserPermission failed: %v", err)
+	}
+
+	interceptor := NewAuthInterceptor(AuthOptions{
+		Permissions: PermissionLoaderFunc(func(_ context.Context, userId string) (*shareddomain.UserPermission, error) {
+			return loaded, nil
+		}),
+	})
+
+	tests := []struct {
+		name      string
+		payload   string
+		signature string
+		action    string
+	}{
+		{"signed permissions", payload, signature, "READ"},
+		{"unsigned permissions", payload, "", "WRITE"},
+		{"invalid signature", payload, "invalid", "WRITE"},
+		{"missing permissions", "", "", "WRITE"},
+	}
+
+	for _, test := range tests {
+		md := metadata.MD{}
+		md.Set("authorization", "Bearer "+token)
+		if test.payload != "" {
+			md.Set("grpc-user-permission", test.payload)
+		}
+		if test.signature != "" {
+			md.Set("grpc-user-permission-signature", test.signature)
+		}
+
+		var action string
+		_, err := interceptor.Unary()(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"},
+			func(ctx context.Context, _ any) (any, error) {
+				if principal := grpcutil.GetGrpcPrincipal(ctx); principal != nil && principal.UserPermission != nil {
+					action = principal.UserPermission.Permissions[0].Action
+				}
+				return nil, nil
+			})
+
+		if err != nil {
+			t.Errorf("Unary failed for %s: %v", test.name, err)
+		}
+		if action != test.action {
+			t.Errorf("Unary failed for %s: expected action %s but got %s", test.name, test.action, action)
+		}
+	}
+}
+
+// TestAuthInterceptorPermissionsChanged is a function to test that permissions changed since the token was issued
+// are loaded instead of denied.
+func TestAuthInterceptorPermissionsChanged(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+
+	issued := &shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "user", Action: "READ"}}}
+	changed := &shareddomain.UserPermission{Permissions: []*shareddomain.Permission{{Resource: "user", Action: "WRITE"}}}
+	token, _ := util.GenerateToken(&domain.GrpcUser{Id: "user-1"}, false, util.WithPermissionsHash(issued))
+
+	payload, _ := json.Marshal(changed)
+	md := metadata.MD{}
+	md.Set("authorization", "Bearer "+token)
+	md.Set("grpc-user-permission", string(payload))
+
+	interceptor := NewAuthInterceptor(AuthOptions{
+		Permissions: PermissionLoaderFunc(func(_ context.Context, userId string) (*shareddomain.UserPermission, error) {
+			return changed, nil
+		}),
+	})
+
+	var action string
+	_, err := interceptor.Unary()(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"},
+		func(ctx context.Context, _ any) (any, error) {
+			action = grpcutil.GetGrpcPrincipal(ctx).UserPermission.Permissions[0].Action
+			return nil, nil
+		})
+
+	if err != nil {
+		t.Fatalf("Unary failed: %v", err)
+	}
+	if action != "WRITE" {
+		t.Errorf("Unary failed: expected the loaded action %s but got %s", "WRITE", action)
+	}
+}
+
+// TestAuthInterceptorService is a function to test the principal of service tokens.
+func TestAuthInterceptorService(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+
+	source := NewServiceTokenSource("billing-job", []string{"invoice:read"}, nil)
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	if cached, _ := source.Token(context.Background()); cached != token {
+		t.Errorf("Token failed: expected cached token")
+	}
+
+	md := metadata.Pairs("authorization", "Bearer "+token, "grpc-user-permission", `{"permissions":[{"action":"READ","resource":"user"}]}`)
+
+	var principal *domain.SweGrpcPrincipal
+	_, err = NewAuthInterceptor(AuthOptions{}).Unary()(metadata.NewIncomingContext(context.Background(), md), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/invoice.InvoiceService/Get"},
+		func(ctx context.Context, _ any) (any, error) {
+			principal = grpcutil.GetGrpcPrincipal(ctx)
+			return nil, nil
+		})
+
+	if err != nil {
+		t.Fatalf("Unary failed: %v", err)
+	}
+	if !principal.IsService() || principal.ClientId != "billing-job" || len(principal.Scopes) != 1 || principal.Scopes[0] != "invoice:read" {
+		t.Errorf("Unary failed: unexpected service principal %+v", principal)
+	}
+	if principal.UserPermission != nil {
+		t.Errorf("Unary failed: expected no user permission but got %v", principal.UserPermission)
+	}
+}
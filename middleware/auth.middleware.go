@@ -2,57 +2,34 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"github.com/ngdangkietswe/swe-go-common-shared/config"
-	"github.com/ngdangkietswe/swe-go-common-shared/constants"
-	"github.com/ngdangkietswe/swe-go-common-shared/domain"
-	"github.com/ngdangkietswe/swe-go-common-shared/grpc/constant"
-	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
-	"github.com/ngdangkietswe/swe-go-common-shared/util"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
-	"strings"
+	"sync"
 )
 
-// AuthMiddleware is a middleware function that checks the token in the request header
-func AuthMiddleware(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return nil, fmt.Errorf("metadata not found")
-	}
-
-	tokens := md.Get(strings.ToLower(constants.AuthorizationHeader))
-	userPermissions := md.Get(strings.ToLower(constants.GrpcMetadataUserPermission))
+// authMiddleware is the interceptor of AuthMiddleware, built on first use from the JWT_* config keys.
+var authMiddleware = sync.OnceValue(func() grpc.UnaryServerInterceptor {
+	return NewAuthInterceptor(AuthOptions{AllowAnonymous: true, Revocation: currentRevocation{}}).Unary()
+})
 
-	if len(tokens) > 0 {
-		value := tokens[0]
-		if value == "" || !strings.HasPrefix(value, constants.TokenPrefix) {
-			return nil, fmt.Errorf("missing or invalid token")
-		} else {
-			token := strings.TrimSpace(strings.TrimPrefix(value, constants.TokenPrefix))
-			jwtClaims, err := util.ParseToken(token, config.GetString("JWT_SECRET", ""))
-			if err != nil {
-				return nil, fmt.Errorf("invalid token")
-			}
+// currentRevocation is a RevocationChecker delegating to the checker of SetDefaultRevocation at check time.
+type currentRevocation struct{}
 
-			principal, err := grpcutil.AsGrpcPrincipal(jwtClaims)
-			if err != nil {
-				return nil, fmt.Errorf("invalid token")
-			}
+func (currentRevocation) IsRevoked(ctx context.Context, jti, familyId string) (bool, error) {
+	revocationMu.RLock()
+	revocation := defaultRevocation
+	revocationMu.RUnlock()
 
-			if userPermissions != nil && len(userPermissions) > 0 {
-				var userPermission *domain.UserPermission
-				err = json.Unmarshal([]byte(userPermissions[0]), &userPermission)
-				if err != nil {
-					return nil, fmt.Errorf("invalid user permissions")
-				}
-				principal.UserPermission = userPermission
-			}
-
-			ctx = context.WithValue(ctx, constant.CtxPrincipalKey, principal)
-		}
+	if revocation == nil {
+		return false, nil
 	}
+	return revocation.IsRevoked(ctx, jti, familyId)
+}
 
-	return handler(ctx, req)
+// AuthMiddleware is a middleware function that checks the token in the request header.
+// Calls without credentials are let through anonymously. Revoked tokens are rejected once SetDefaultRevocation is set.
+//
+// Deprecated: use NewAuthInterceptor, which rejects missing credentials outside the public methods
+// and also covers streams.
+func AuthMiddleware(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	return authMiddleware()(ctx, req, info, handler)
 }
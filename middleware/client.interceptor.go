@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"time"
+)
+
+// TokenSource provides the token of the service identity, used when the call has no principal.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// ClientOptions configures the unary and stream client interceptors.
+type ClientOptions struct {
+	// TokenSource injects a service identity token into calls made without a principal. Nil sends them without credentials.
+	TokenSource TokenSource
+
+	// Timeout is the deadline of calls whose context has none. Defaults to the GRPC_CLIENT_TIMEOUT_MS config key,
+	// a negative value disables it.
+	Timeout time.Duration
+}
+
+// ClientInterceptor propagates the credentials, request id and correlation id of outgoing calls,
+// as consumed by AuthInterceptor on the server side.
+type ClientInterceptor struct {
+	options ClientOptions
+}
+
+// NewClientInterceptor creates the client interceptors of the given options.
+func NewClientInterceptor(options ClientOptions) *ClientInterceptor {
+	if options.Timeout == 0 {
+		options.Timeout = time.Duration(config.GetInt("GRPC_CLIENT_TIMEOUT_MS", 30000)) * time.Millisecond
+	}
+
+	return &ClientInterceptor{options: options}
+}
+
+// Unary returns the unary client interceptor.
+func (c *ClientInterceptor) Unary() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := c.outgoing(ctx)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := c.withTimeout(ctx)
+		defer cancel()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Stream returns the stream client interceptor. The timeout is not applied to streams, whose lifetime is up to the caller.
+func (c *ClientInterceptor) Stream() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := c.outgoing(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// outgoing returns the context with the outgoing metadata of the call. Metadata already set by the caller is kept.
+func (c *ClientInterceptor) outgoing(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	incoming, _ := metadata.FromIncomingContext(ctx)
+
+	authorization := strings.ToLower(constants.AuthorizationHeader)
+	if len(md.Get(authorization)) == 0 {
+		if principal := grpcutil.GetGrpcPrincipal(ctx); principal != nil && principal.Token != "" {
+			md.Set(authorization, fmt.Sprintf("%s %s", constants.TokenPrefix, principal.Token))
+
+			// The signed permissions are only valid along with the token of the principal.
+			for _, key := range []string{constants.GrpcMetadataUserPermission, constants.GrpcMetadataUserPermissionSignature} {
+				if values := incoming.Get(key); len(values) > 0 && len(md.Get(key)) == 0 {
+					md.Set(key, values...)
+				}
+			}
+		} else if c.options.TokenSource != nil {
+			token, err := c.options.TokenSource.Token(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get service token: %w", err)
+			}
+			md.Set(authorization, fmt.Sprintf("%s %s", constants.TokenPrefix, token))
+		}
+	}
+
+	requestId := first(md.Get(constants.GrpcMetadataRequestId), incoming.Get(constants.GrpcMetadataRequestId))
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+	md.Set(constants.GrpcMetadataRequestId, requestId)
+
+	// The correlation id spans the whole call chain, starting with the request id of its first call.
+	correlationId := first(md.Get(constants.GrpcMetadataCorrelationId), incoming.Get(constants.GrpcMetadataCorrelationId))
+	if correlationId == "" {
+		correlationId = requestId
+	}
+	md.Set(constants.GrpcMetadataCorrelationId, correlationId)
+
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// withTimeout applies the default timeout to a context without deadline.
+func (c *ClientInterceptor) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.options.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.options.Timeout)
+}
+
+// first returns the first value of the first non-empty list.
+func first(lists ...[]string) string {
+	for _, values := range lists {
+		if len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+	"time"
+)
+
+// invoke runs the unary client interceptor and returns the outgoing metadata and deadline of the call.
+func invoke(t *testing.T, interceptor *ClientInterceptor, ctx context.Context) (metadata.MD, bool) {
+	var md metadata.MD
+	var hasDeadline bool
+	err := interceptor.Unary()(ctx, "/user.UserService/Get", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			_, hasDeadline = ctx.Deadline()
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Unary failed: %v", err)
+	}
+	return md, hasDeadline
+}
+
+// TestClientInterceptorPrincipal is a function to test the propagation of the principal credentials.
+func TestClientInterceptorPrincipal(t *testing.T) {
+	interceptor := NewClientInterceptor(ClientOptions{
+		TokenSource: TokenSourceFunc(func(context.Context) (string, error) {
+			return "service-token", nil
+		}),
+	})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"grpc-user-permission", "{}",
+		"grpc-user-permission-signature", "signature",
+		"x-correlation-id", "correlation-1",
+	))
+	ctx = grpcutil.WithPrincipal(ctx, &domain.SweGrpcPrincipal{UserId: "user-1", Token: "user-token"})
+
+	md, hasDeadline := invoke(t, interceptor, ctx)
+
+	if actual := md.Get("authorization"); len(actual) != 1 || actual[0] != "Bearer user-token" {
+		t.Errorf("Unary failed: expected user token but got %v", actual)
+	}
+	if actual := md.Get("grpc-user-permission-signature"); len(actual) != 1 || actual[0] != "signature" {
+		t.Errorf("Unary failed: expected permission signature but got %v", actual)
+	}
+	if actual := md.Get("x-correlation-id"); len(actual) != 1 || actual[0] != "correlation-1" {
+		t.Errorf("Unary failed: expected correlation id correlation-1 but got %v", actual)
+	}
+	if len(md.Get("x-request-id")) != 1 {
+		t.Errorf("Unary failed: expected request id but got %v", md.Get("x-request-id"))
+	}
+	if !hasDeadline {
+		t.Errorf("Unary failed: expected default deadline")
+	}
+}
+
+// TestClientInterceptorServiceToken is a function to test the service token of calls without principal.
+func TestClientInterceptorServiceToken(t *testing.T) {
+	interceptor := NewClientInterceptor(ClientOptions{
+		TokenSource: TokenSourceFunc(func(context.Context) (string, error) {
+			return "service-token", nil
+		}),
+		Timeout: -1,
+	})
+
+	md, hasDeadline := invoke(t, interceptor, metadata.NewIncomingContext(context.Background(), metadata.Pairs("grpc-user-permission", "{}")))
+
+	if actual := md.Get("authorization"); len(actual) != 1 || actual[0] != "Bearer service-token" {
+		t.Errorf("Unary failed: expected service token but got %v", actual)
+	}
+	if actual := md.Get("grpc-user-permission"); len(actual) != 0 {
+		t.Errorf("Unary failed: expected no user permission but got %v", actual)
+	}
+	if requestId, correlationId := md.Get("x-request-id"), md.Get("x-correlation-id"); len(requestId) != 1 || len(correlationId) != 1 || requestId[0] != correlationId[0] {
+		t.Errorf("Unary failed: expected correlation id %v but got %v", requestId, correlationId)
+	}
+	if hasDeadline {
+		t.Errorf("Unary failed: expected no deadline")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, hasDeadline = invoke(t, interceptor, ctx); !hasDeadline {
+		t.Errorf("Unary failed: expected caller deadline")
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"log"
+	"time"
+)
+
+// PermissionLoader loads the permissions of a user when the propagated ones are missing or invalid.
+type PermissionLoader interface {
+	LoadPermissions(ctx context.Context, userId string) (*domain.UserPermission, error)
+}
+
+// PermissionLoaderFunc adapts a function to a PermissionLoader.
+type PermissionLoaderFunc func(ctx context.Context, userId string) (*domain.UserPermission, error)
+
+func (f PermissionLoaderFunc) LoadPermissions(ctx context.Context, userId string) (*domain.UserPermission, error) {
+	return f(ctx, userId)
+}
+
+// CachedPermissionLoader reads the permissions from the user_permission cache, falling back to the loader on a miss.
+type CachedPermissionLoader struct {
+	cache  *cache.RedisCache
+	loader PermissionLoader
+	ttl    time.Duration
+}
+
+// NewCachedPermissionLoader creates a CachedPermissionLoader. A nil loader only reads the cache.
+func NewCachedPermissionLoader(redisCache *cache.RedisCache, loader PermissionLoader, ttl time.Duration) *CachedPermissionLoader {
+	return &CachedPermissionLoader{
+		cache:  redisCache,
+		loader: loader,
+		ttl:    ttl,
+	}
+}
+
+func (l *CachedPermissionLoader) LoadPermissions(ctx context.Context, userId string) (*domain.UserPermission, error) {
+	var userPermission *domain.UserPermission
+	if err := l.cache.Get(permissionKey(userId), &userPermission); err == nil {
+		return userPermission, nil
+	}
+
+	if l.loader == nil {
+		return nil, nil
+	}
+
+	userPermission, err := l.loader.LoadPermissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = l.cache.Set(permissionKey(userId), userPermission, l.ttl); err != nil {
+		log.Printf("failed to cache permissions of user %s: %v", userId, err)
+	}
+
+	return userPermission, nil
+}
+
+func permissionKey(userId string) string {
+	return fmt.Sprintf("%s:%s", constants.UserPermissionCacheKeyPrefix, userId)
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ngdangkietswe/swe-go-common-shared/jwks"
+	"github.com/ngdangkietswe/swe-go-common-shared/util"
+	"sync"
+	"time"
+)
+
+// serviceTokenRenewal is how long before its expiry a service token is renewed.
+const serviceTokenRenewal = time.Minute
+
+// ServiceTokenSource is a TokenSource minting the service token of a client id, renewed before it expires.
+// It lets backend jobs and consumers without principal call protected methods.
+type ServiceTokenSource struct {
+	clientId string
+	scopes   []string
+	keySet   *jwks.KeySet
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewServiceTokenSource creates a ServiceTokenSource. Tokens are signed by the active key of the key set,
+// or with JWT_SECRET when it is nil.
+func NewServiceTokenSource(clientId string, scopes []string, keySet *jwks.KeySet) *ServiceTokenSource {
+	return &ServiceTokenSource{
+		clientId: clientId,
+		scopes:   scopes,
+		keySet:   keySet,
+	}
+}
+
+func (s *ServiceTokenSource) Token(_ context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(serviceTokenRenewal).Before(s.expiresAt) {
+		return s.token, nil
+	}
+
+	var token string
+	var err error
+	if s.keySet != nil {
+		token, err = util.GenerateServiceTokenWithKeySet(s.keySet, s.clientId, s.scopes)
+	} else {
+		token, err = util.GenerateServiceToken(s.clientId, s.scopes)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate service token: %w", err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return "", fmt.Errorf("failed to parse service token: %w", err)
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return "", fmt.Errorf("service token has no expiration time")
+	}
+
+	s.token, s.expiresAt = token, exp.Time
+	return token, nil
+}
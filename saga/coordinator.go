@@ -0,0 +1,315 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/consumer"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxConflictRetries is the number of times a reply is applied again after a concurrent update of its saga.
+const maxConflictRetries = 3
+
+// Coordinator runs saga instances: it emits step commands, reacts to participant replies and
+// compensates completed steps when a step fails or times out. Coordinators of several instances
+// may share a store, the versioned saves of the store reject concurrent transitions.
+type Coordinator struct {
+	store       Store
+	publisher   producer.Publisher
+	definitions map[string]*Definition
+
+	// mu serializes the state transitions of this coordinator.
+	mu sync.Mutex
+}
+
+func NewCoordinator(store Store, publisher producer.Publisher) *Coordinator {
+	return &Coordinator{
+		store:       store,
+		publisher:   publisher,
+		definitions: make(map[string]*Definition),
+	}
+}
+
+// Register adds a saga definition. Definitions need at least one step.
+func (c *Coordinator) Register(definition *Definition) error {
+	if definition == nil || len(definition.Steps) == 0 {
+		return errors.New("saga definition has no steps")
+	}
+
+	c.definitions[definition.Name] = definition
+	return nil
+}
+
+// Topics returns the sorted reply topics of the registered sagas.
+func (c *Coordinator) Topics() []string {
+	seen := make(map[string]bool)
+	for _, definition := range c.definitions {
+		for _, step := range definition.Steps {
+			for _, topic := range []string{step.SuccessTopic, step.FailureTopic} {
+				if topic != "" {
+					seen[topic] = true
+				}
+			}
+		}
+	}
+
+	topics := make([]string, 0, len(seen))
+	for topic := range seen {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Subscribe registers the coordinator on the router for every reply topic.
+func (c *Coordinator) Subscribe(router *consumer.Router) {
+	for _, topic := range c.Topics() {
+		router.Handle(topic, c.Handle)
+	}
+}
+
+// Start creates a saga instance with the given data and starts its first step.
+// When the first command cannot be emitted, the saga is compensated and the emit error is returned.
+func (c *Coordinator) Start(ctx context.Context, sagaName string, data interface{}) (*State, error) {
+	definition, ok := c.definitions[sagaName]
+	if !ok {
+		return nil, fmt.Errorf("saga %s not registered", sagaName)
+	}
+	if len(definition.Steps) == 0 {
+		return nil, fmt.Errorf("saga %s has no steps", sagaName)
+	}
+
+	now := time.Now()
+	state := &State{
+		Id:        uuid.NewString(),
+		Saga:      sagaName,
+		Status:    StatusRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := state.Update(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal saga data: %w", err)
+	}
+
+	for _, step := range definition.Steps {
+		state.Steps = append(state.Steps, StepState{Name: step.Name, Status: StepStatusPending})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.startStep(ctx, definition, state); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+// Status returns the current state of a saga instance.
+func (c *Coordinator) Status(ctx context.Context, id string) (*State, error) {
+	return c.store.Load(ctx, id)
+}
+
+// Handle processes a participant reply keyed by the saga ID. Replies for other steps or finished sagas are ignored.
+// The consumers commit the reply before it is handled, so when another coordinator updated the saga meanwhile,
+// the reply is applied again to the reloaded state, up to maxConflictRetries times.
+func (c *Coordinator) Handle(ctx context.Context, msg kafka.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt <= maxConflictRetries; attempt++ {
+		if err = c.handle(ctx, msg); !errors.Is(err, ErrConflict) {
+			return err
+		}
+		log.Printf("Saga %s updated concurrently, applying reply of topic %s again", string(msg.Key), msg.Topic)
+	}
+	return err
+}
+
+// handle applies a reply to the stored state of its saga.
+func (c *Coordinator) handle(ctx context.Context, msg kafka.Message) error {
+	state, err := c.store.Load(ctx, string(msg.Key))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	definition, ok := c.definitions[state.Saga]
+	if !ok || state.Status != StatusRunning || state.CurrentStep >= len(definition.Steps) {
+		return nil
+	}
+
+	step := definition.Steps[state.CurrentStep]
+	switch msg.Topic {
+	case step.SuccessTopic:
+		if step.OnSuccess != nil {
+			if err = step.OnSuccess(ctx, state, msg.Value); err != nil {
+				return c.fail(ctx, definition, state, fmt.Errorf("failed to apply reply of step %s: %w", step.Name, err))
+			}
+		}
+
+		now := time.Now()
+		state.Steps[state.CurrentStep].Status = StepStatusCompleted
+		state.Steps[state.CurrentStep].CompletedAt = &now
+
+		if state.CurrentStep == len(definition.Steps)-1 {
+			state.Status = StatusCompleted
+			state.Deadline = nil
+			return c.save(ctx, state)
+		}
+
+		state.CurrentStep++
+		return c.startStep(ctx, definition, state)
+
+	case step.FailureTopic:
+		return c.fail(ctx, definition, state, fmt.Errorf("step %s failed: %s", step.Name, string(msg.Value)))
+	}
+
+	return nil
+}
+
+// RunTimeouts periodically fails the running steps whose deadline has passed, until the context is done.
+func (c *Coordinator) RunTimeouts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.CheckTimeouts(ctx); err != nil {
+				log.Printf("Error while checking saga timeouts: %v", err)
+			}
+		}
+	}
+}
+
+// CheckTimeouts fails the running steps whose deadline has passed.
+func (c *Coordinator) CheckTimeouts(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states, err := c.store.ListRunning(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, state := range states {
+		definition, ok := c.definitions[state.Saga]
+		if !ok || state.Deadline == nil || now.Before(*state.Deadline) || state.CurrentStep >= len(definition.Steps) {
+			continue
+		}
+
+		step := definition.Steps[state.CurrentStep]
+		if err = c.fail(ctx, definition, state, fmt.Errorf("step %s timed out", step.Name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// startStep marks the current step as running and emits its command.
+func (c *Coordinator) startStep(ctx context.Context, definition *Definition, state *State) error {
+	step := definition.Steps[state.CurrentStep]
+
+	now := time.Now()
+	state.Steps[state.CurrentStep].Status = StepStatusRunning
+	state.Steps[state.CurrentStep].StartedAt = &now
+	state.Deadline = nil
+	if step.Timeout > 0 {
+		deadline := now.Add(step.Timeout)
+		state.Deadline = &deadline
+	}
+
+	// Persist before emitting, so that a fast reply finds the step running.
+	if err := c.save(ctx, state); err != nil {
+		return err
+	}
+
+	payload, err := buildPayload(ctx, step.Name, state, step.Command)
+	if err == nil {
+		err = c.publisher.Publish(ctx, state.Id, step.CommandTopic, payload)
+	}
+
+	if err != nil {
+		err = fmt.Errorf("failed to emit command of step %s: %w", step.Name, err)
+		return errors.Join(err, c.fail(ctx, definition, state, err))
+	}
+	return nil
+}
+
+// fail marks the current step as failed and compensates the completed steps in reverse order.
+func (c *Coordinator) fail(ctx context.Context, definition *Definition, state *State, cause error) error {
+	log.Printf("Saga %s (%s) failed, compensating: %v", state.Id, state.Saga, cause)
+
+	state.Status = StatusCompensating
+	state.Error = cause.Error()
+	state.Deadline = nil
+	state.Steps[state.CurrentStep].Status = StepStatusFailed
+	state.Steps[state.CurrentStep].Error = cause.Error()
+
+	if err := c.save(ctx, state); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := state.CurrentStep - 1; i >= 0; i-- {
+		step := definition.Steps[i]
+		if step.CompensationTopic == "" {
+			continue
+		}
+
+		payload, err := buildPayload(ctx, step.Name, state, step.Compensation)
+		if err == nil {
+			err = c.publisher.Publish(ctx, state.Id, step.CompensationTopic, payload)
+		}
+
+		if err != nil {
+			state.Steps[i].Error = err.Error()
+			errs = append(errs, fmt.Errorf("failed to compensate step %s: %w", step.Name, err))
+			continue
+		}
+		state.Steps[i].Status = StepStatusCompensated
+	}
+
+	// Failed compensations keep the saga compensating for an operator to resolve.
+	if len(errs) == 0 {
+		state.Status = StatusCompensated
+	}
+
+	if err := c.save(ctx, state); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// save persists the state with a fresh update time.
+func (c *Coordinator) save(ctx context.Context, state *State) error {
+	state.UpdatedAt = time.Now()
+	if err := c.store.Save(ctx, state); err != nil {
+		return fmt.Errorf("failed to save saga %s: %w", state.Id, err)
+	}
+	return nil
+}
+
+// buildPayload returns the payload built by the builder, defaulting to a Command with the saga data.
+func buildPayload(ctx context.Context, stepName string, state *State, builder func(context.Context, *State) (interface{}, error)) (interface{}, error) {
+	if builder != nil {
+		return builder(ctx, state)
+	}
+	return Command{SagaId: state.Id, Step: stepName, Data: json.RawMessage(state.Data)}, nil
+}
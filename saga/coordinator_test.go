@@ -0,0 +1,270 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/kafkatest"
+	"github.com/ngdangkietswe/swe-go-common-shared/kafka/producer"
+	"github.com/segmentio/kafka-go"
+	"strings"
+	"testing"
+	"time"
+)
+
+type registration struct {
+	Email     string `json:"email"`
+	ProfileId string `json:"profile_id,omitempty"`
+}
+
+// newRegistrationSaga returns a coordinator with a two steps registration saga on an in-memory broker.
+func newRegistrationSaga(timeout time.Duration) (*Coordinator, *kafkatest.Broker) {
+	broker := kafkatest.NewBroker()
+	coordinator := NewCoordinator(NewMemoryStore(), broker.Publisher())
+	_ = coordinator.Register(&Definition{
+		Name: "register_user",
+		Steps: []Step{
+			{
+				Name:              "create_profile",
+				CommandTopic:      "profile.create.v1",
+				SuccessTopic:      "profile.created.v1",
+				FailureTopic:      "profile.failed.v1",
+				CompensationTopic: "profile.delete.v1",
+				Timeout:           time.Minute,
+				OnSuccess: func(_ context.Context, state *State, reply []byte) error {
+					var data registration
+					if err := state.Decode(&data); err != nil {
+						return err
+					}
+					data.ProfileId = string(reply)
+					return state.Update(data)
+				},
+			},
+			{
+				Name:         "send_welcome",
+				CommandTopic: "notification.send.v1",
+				SuccessTopic: "notification.sent.v1",
+				FailureTopic: "notification.failed.v1",
+				Timeout:      timeout,
+			},
+		},
+	})
+	return coordinator, broker
+}
+
+// reply simulates a participant reply for the saga.
+func reply(t *testing.T, coordinator *Coordinator, id, topic, value string) {
+	t.Helper()
+	if err := coordinator.Handle(context.Background(), kafka.Message{Topic: topic, Key: []byte(id), Value: []byte(value)}); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+}
+
+// assertStatus checks the saga status through the query API.
+func assertStatus(t *testing.T, coordinator *Coordinator, id string, expected Status) *State {
+	t.Helper()
+	state, err := coordinator.Status(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if state.Status != expected {
+		t.Errorf("Status failed: expected %v but got %v", expected, state.Status)
+	}
+	return state
+}
+
+// TestCoordinatorCompleted is a function to test a saga running every step to completion.
+func TestCoordinatorCompleted(t *testing.T) {
+	coordinator, broker := newRegistrationSaga(time.Minute)
+	ctx := context.Background()
+
+	state, err := coordinator.Start(ctx, "register_user", registration{Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	broker.ExpectPublished(t, "profile.create.v1", kafkatest.KeyEquals(state.Id))
+
+	// Replies for another step are ignored.
+	reply(t, coordinator, state.Id, "notification.sent.v1", "")
+	assertStatus(t, coordinator, state.Id, StatusRunning)
+
+	reply(t, coordinator, state.Id, "profile.created.v1", "profile-1")
+	broker.ExpectPublished(t, "notification.send.v1", kafkatest.JSONEquals(Command{
+		SagaId: state.Id,
+		Step:   "send_welcome",
+		Data:   []byte(`{"email":"john@example.com","profile_id":"profile-1"}`),
+	}))
+
+	reply(t, coordinator, state.Id, "notification.sent.v1", "")
+	state = assertStatus(t, coordinator, state.Id, StatusCompleted)
+
+	for _, step := range state.Steps {
+		if step.Status != StepStatusCompleted {
+			t.Errorf("Status failed: expected step %s %v but got %v", step.Name, StepStatusCompleted, step.Status)
+		}
+	}
+	broker.ExpectNotPublished(t, "profile.delete.v1", kafkatest.Any())
+}
+
+// TestCoordinatorCompensated is a function to test the compensation of a saga after a failed step.
+func TestCoordinatorCompensated(t *testing.T) {
+	coordinator, broker := newRegistrationSaga(time.Minute)
+	ctx := context.Background()
+
+	state, err := coordinator.Start(ctx, "register_user", registration{Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	reply(t, coordinator, state.Id, "profile.created.v1", "profile-1")
+	reply(t, coordinator, state.Id, "notification.failed.v1", "smtp unavailable")
+
+	state = assertStatus(t, coordinator, state.Id, StatusCompensated)
+	if state.Steps[0].Status != StepStatusCompensated || state.Steps[1].Status != StepStatusFailed {
+		t.Errorf("Status failed: unexpected steps %+v", state.Steps)
+	}
+	broker.ExpectPublished(t, "profile.delete.v1", kafkatest.KeyEquals(state.Id))
+
+	// Late replies do not resume a finished saga.
+	reply(t, coordinator, state.Id, "notification.sent.v1", "")
+	assertStatus(t, coordinator, state.Id, StatusCompensated)
+}
+
+// TestCoordinatorTimeout is a function to test the compensation of a saga after a step timed out.
+func TestCoordinatorTimeout(t *testing.T) {
+	coordinator, broker := newRegistrationSaga(time.Millisecond)
+	ctx := context.Background()
+
+	state, err := coordinator.Start(ctx, "register_user", registration{Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	reply(t, coordinator, state.Id, "profile.created.v1", "profile-1")
+	time.Sleep(5 * time.Millisecond)
+
+	if err = coordinator.CheckTimeouts(ctx); err != nil {
+		t.Fatalf("CheckTimeouts failed: %v", err)
+	}
+
+	state = assertStatus(t, coordinator, state.Id, StatusCompensated)
+	if state.Steps[1].Error != "step send_welcome timed out" {
+		t.Errorf("CheckTimeouts failed: expected timeout error but got %q", state.Steps[1].Error)
+	}
+	broker.ExpectPublished(t, "profile.delete.v1", kafkatest.KeyEquals(state.Id))
+}
+
+// failingPublisher is a producer.Publisher failing every publish.
+type failingPublisher struct {
+	*kafkatest.Publisher
+}
+
+func (failingPublisher) Publish(context.Context, string, string, interface{}, ...producer.ProduceOption) error {
+	return errors.New("broker unavailable")
+}
+
+// TestCoordinatorStartFailed is a function to test that Start returns the error of the first command.
+func TestCoordinatorStartFailed(t *testing.T) {
+	coordinator := NewCoordinator(NewMemoryStore(), failingPublisher{})
+	_ = coordinator.Register(&Definition{
+		Name:  "register_user",
+		Steps: []Step{{Name: "create_profile", CommandTopic: "profile.create.v1"}},
+	})
+
+	state, err := coordinator.Start(context.Background(), "register_user", registration{Email: "john@example.com"})
+	if err == nil || !strings.Contains(err.Error(), "broker unavailable") {
+		t.Fatalf("Start failed: expected the publish error but got %v", err)
+	}
+	assertStatus(t, coordinator, state.Id, StatusCompensated)
+}
+
+// TestCoordinatorEmptyDefinition is a function to test that sagas without steps are rejected.
+func TestCoordinatorEmptyDefinition(t *testing.T) {
+	coordinator := NewCoordinator(NewMemoryStore(), kafkatest.NewBroker().Publisher())
+
+	if err := coordinator.Register(&Definition{Name: "empty"}); err == nil {
+		t.Errorf("Register failed: expected an error for a saga without steps")
+	}
+	if _, err := coordinator.Start(context.Background(), "empty", nil); err == nil {
+		t.Errorf("Start failed: expected an error for an unregistered saga")
+	}
+}
+
+// racingStore is a Store where another coordinator saves the saga between the first Load and Save.
+type racingStore struct {
+	Store
+	raced bool
+}
+
+func (s *racingStore) Load(ctx context.Context, id string) (*State, error) {
+	state, err := s.Store.Load(ctx, id)
+	if err != nil || s.raced {
+		return state, err
+	}
+
+	s.raced = true
+	concurrent, err := s.Store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	concurrent.UpdatedAt = time.Now()
+	return state, s.Store.Save(ctx, concurrent)
+}
+
+// TestCoordinatorHandleConflict is a function to test that a reply is applied again after a concurrent update.
+func TestCoordinatorHandleConflict(t *testing.T) {
+	coordinator, broker := newRegistrationSaga(time.Minute)
+	ctx := context.Background()
+
+	state, err := coordinator.Start(ctx, "register_user", registration{Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	store := &racingStore{Store: coordinator.store}
+	coordinator.store = store
+
+	reply(t, coordinator, state.Id, "profile.created.v1", "profile-1")
+	if !store.raced {
+		t.Fatalf("Handle failed: expected a concurrent save")
+	}
+
+	state = assertStatus(t, coordinator, state.Id, StatusRunning)
+	if state.CurrentStep != 1 || state.Steps[0].Status != StepStatusCompleted || state.Version != 3 {
+		t.Errorf("Handle failed: expected the first step completed at version %d but got %+v", 3, state)
+	}
+	broker.ExpectPublished(t, "notification.send.v1", kafkatest.KeyEquals(state.Id))
+}
+
+// TestMemoryStoreConflict is a function to test that a stale state is not saved over a newer one.
+func TestMemoryStoreConflict(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	state := &State{Id: "saga-1", Status: StatusRunning}
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	stale, _ := store.Load(ctx, state.Id)
+	state.Status = StatusCompleted
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	stale.Status = StatusCompensating
+	if err := store.Save(ctx, stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save failed: expected %v but got %v", ErrConflict, err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("Save failed: expected version %d but got %d", 1, stale.Version)
+	}
+	if err := store.Save(ctx, &State{Id: state.Id}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save failed: expected %v creating an existing saga but got %v", ErrConflict, err)
+	}
+
+	loaded, _ := store.Load(ctx, state.Id)
+	if loaded.Status != StatusCompleted || loaded.Version != 2 {
+		t.Errorf("Load failed: expected %v at version %d but got %v at version %d", StatusCompleted, 2, loaded.Status, loaded.Version)
+	}
+}
@@ -0,0 +1,133 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusRunning      Status = "RUNNING"
+	StatusCompleted    Status = "COMPLETED"
+	StatusCompensating Status = "COMPENSATING"
+	StatusCompensated  Status = "COMPENSATED"
+)
+
+type StepStatus string
+
+const (
+	StepStatusPending     StepStatus = "PENDING"
+	StepStatusRunning     StepStatus = "RUNNING"
+	StepStatusCompleted   StepStatus = "COMPLETED"
+	StepStatusFailed      StepStatus = "FAILED"
+	StepStatusCompensated StepStatus = "COMPENSATED"
+)
+
+var (
+	// ErrNotFound is returned when a saga does not exist in the store.
+	ErrNotFound = errors.New("saga: not found")
+
+	// ErrConflict is returned when a saga was saved by another coordinator since it was loaded.
+	ErrConflict = errors.New("saga: concurrent update")
+)
+
+// Command is the default payload of the commands emitted for a step and its compensation.
+type Command struct {
+	SagaId string          `json:"saga_id"`
+	Step   string          `json:"step"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Step is a step of a saga. Starting the step publishes a command to CommandTopic keyed by the saga ID.
+// The participant replies keyed by the saga ID on SuccessTopic or FailureTopic. Without a reply within
+// Timeout the step fails. Completed steps are compensated in reverse order by publishing to CompensationTopic.
+type Step struct {
+	Name              string
+	CommandTopic      string
+	SuccessTopic      string
+	FailureTopic      string
+	CompensationTopic string
+	Timeout           time.Duration
+
+	// Command builds the command payload. Defaults to a Command with the saga data.
+	Command func(ctx context.Context, state *State) (interface{}, error)
+
+	// Compensation builds the compensation payload. Defaults to a Command with the saga data.
+	Compensation func(ctx context.Context, state *State) (interface{}, error)
+
+	// OnSuccess may update the saga data from the success reply.
+	OnSuccess func(ctx context.Context, state *State, reply []byte) error
+}
+
+// Definition declares the steps of a saga.
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// StepState is the progress of a step of a saga instance.
+type StepState struct {
+	Name        string     `json:"name"`
+	Status      StepStatus `json:"status"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// State is the persisted state of a saga instance.
+type State struct {
+	Id          string          `json:"id"`
+	Saga        string          `json:"saga"`
+	Status      Status          `json:"status"`
+	CurrentStep int             `json:"current_step"`
+	Data        json.RawMessage `json:"data"`
+	Steps       []StepState     `json:"steps"`
+	Deadline    *time.Time      `json:"deadline,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	// Version is incremented by every save, so that concurrent coordinators do not overwrite each other.
+	Version int64 `json:"version"`
+}
+
+// Decode decodes the saga data into target.
+func (s *State) Decode(target interface{}) error {
+	return json.Unmarshal(s.Data, target)
+}
+
+// Update replaces the saga data with the JSON encoding of value.
+func (s *State) Update(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.Data = data
+	return nil
+}
+
+// Store persists saga states.
+type Store interface {
+	// Save stores the state if the stored version still is state.Version, zero for a new saga, and increments it.
+	// It returns ErrConflict otherwise.
+	Save(ctx context.Context, state *State) error
+	Load(ctx context.Context, id string) (*State, error)
+
+	// ListRunning returns the sagas in the RUNNING status, used to detect timed out steps.
+	ListRunning(ctx context.Context) ([]*State, error)
+}
+
+// marshalNext returns the JSON encoding of the state at its next version, with the version expected in the store.
+// The state keeps its version until the store confirms the save.
+func marshalNext(state *State) ([]byte, int64, error) {
+	expected := state.Version
+
+	next := *state
+	next.Version = expected + 1
+
+	data, err := json.Marshal(&next)
+	return data, expected, err
+}
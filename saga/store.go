@@ -0,0 +1,298 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store, suitable for tests and single instance deployments.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	states map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string][]byte)}
+}
+
+// Save stores a copy of the state.
+func (s *MemoryStore) Save(_ context.Context, state *State) error {
+	data, expected, err := marshalNext(state)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var version int64
+	if current, ok := s.states[state.Id]; ok {
+		var stored State
+		if err = json.Unmarshal(current, &stored); err != nil {
+			return err
+		}
+		version = stored.Version
+	}
+	if version != expected {
+		return ErrConflict
+	}
+
+	s.states[state.Id] = data
+	state.Version = expected + 1
+	return nil
+}
+
+// Load returns a copy of the state.
+func (s *MemoryStore) Load(_ context.Context, id string) (*State, error) {
+	s.mu.RLock()
+	data, ok := s.states[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListRunning returns the running sagas.
+func (s *MemoryStore) ListRunning(ctx context.Context) ([]*State, error) {
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.states))
+	for id := range s.states {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+
+	var states []*State
+	for _, id := range ids {
+		state, err := s.Load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if state.Status == StatusRunning {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// saveScript compares the version of the stored state before replacing it and updating the running index.
+// KEYS: state, running index. ARGV: expected version, state, expiration in ms, running flag, saga id.
+var saveScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+local version = 0
+if current then
+	version = cjson.decode(current).version or 0
+end
+if version ~= tonumber(ARGV[1]) then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+if ARGV[4] == '1' then
+	redis.call('SADD', KEYS[2], ARGV[5])
+else
+	redis.call('SREM', KEYS[2], ARGV[5])
+end
+return 1
+`)
+
+// RedisStore is a Store sharing the connection of the cache. States are stored as JSON under saga:<id>
+// and the running sagas are indexed in the saga:running set.
+type RedisStore struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+// NewRedisStore creates a RedisStore. Finished sagas expire after retention, zero keeps them forever.
+func NewRedisStore(redisCache *cache.RedisCache, retention time.Duration) *RedisStore {
+	return &RedisStore{client: redisCache.Client(), retention: retention}
+}
+
+// Save stores the state and maintains the running index.
+func (s *RedisStore) Save(ctx context.Context, state *State) error {
+	data, expected, err := marshalNext(state)
+	if err != nil {
+		return err
+	}
+
+	var expiration time.Duration
+	if state.Status != StatusRunning && state.Status != StatusCompensating {
+		expiration = s.retention
+	}
+
+	running := "0"
+	if state.Status == StatusRunning {
+		running = "1"
+	}
+
+	saved, err := saveScript.Run(ctx, s.client, []string{stateKey(state.Id), runningKey()},
+		expected, data, expiration.Milliseconds(), running, state.Id).Int()
+	if err != nil {
+		return fmt.Errorf("failed to save saga %s: %w", state.Id, err)
+	}
+	if saved == 0 {
+		return ErrConflict
+	}
+
+	state.Version = expected + 1
+	return nil
+}
+
+// Load returns the state of the saga.
+func (s *RedisStore) Load(ctx context.Context, id string) (*State, error) {
+	data, err := s.client.Get(ctx, stateKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load saga %s: %w", id, err)
+	}
+
+	var state State
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListRunning returns the sagas of the running index.
+func (s *RedisStore) ListRunning(ctx context.Context) ([]*State, error) {
+	ids, err := s.client.SMembers(ctx, runningKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running sagas: %w", err)
+	}
+
+	var states []*State
+	for _, id := range ids {
+		state, err := s.Load(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func stateKey(id string) string {
+	return fmt.Sprintf("%s:%s", constants.SagaCacheKeyPrefix, id)
+}
+
+func runningKey() string {
+	return fmt.Sprintf("%s:running", constants.SagaCacheKeyPrefix)
+}
+
+// SQLStore is a Store on a PostgreSQL table created with:
+//
+//	CREATE TABLE saga_states (
+//	    id         VARCHAR(36) PRIMARY KEY,
+//	    saga       VARCHAR(255) NOT NULL,
+//	    status     VARCHAR(32)  NOT NULL,
+//	    state      JSONB        NOT NULL,
+//	    version    BIGINT       NOT NULL,
+//	    updated_at TIMESTAMPTZ  NOT NULL
+//	);
+//	CREATE INDEX idx_saga_states_status ON saga_states (status);
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore creates a SQLStore on the given table, saga_states if empty.
+func NewSQLStore(db *sql.DB, table string) *SQLStore {
+	if table == "" {
+		table = "saga_states"
+	}
+	return &SQLStore{db: db, table: table}
+}
+
+// Save inserts a new state, or updates the state of the expected version.
+func (s *SQLStore) Save(ctx context.Context, state *State) error {
+	data, expected, err := marshalNext(state)
+	if err != nil {
+		return err
+	}
+
+	// The JSON is passed as text, drivers send []byte as bytea which PostgreSQL does not cast to JSONB.
+	var result sql.Result
+	if expected == 0 {
+		query := fmt.Sprintf(`INSERT INTO %s (id, saga, status, state, version, updated_at) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO NOTHING`, s.table)
+		result, err = s.db.ExecContext(ctx, query, state.Id, state.Saga, string(state.Status), string(data), expected+1, state.UpdatedAt)
+	} else {
+		query := fmt.Sprintf(`UPDATE %s SET status = $2, state = $3, version = $4, updated_at = $5 WHERE id = $1 AND version = $6`, s.table)
+		result, err = s.db.ExecContext(ctx, query, state.Id, string(state.Status), string(data), expected+1, state.UpdatedAt, expected)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save saga %s: %w", state.Id, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save saga %s: %w", state.Id, err)
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+
+	state.Version = expected + 1
+	return nil
+}
+
+// Load returns the state of the saga.
+func (s *SQLStore) Load(ctx context.Context, id string) (*State, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT state FROM %s WHERE id = $1", s.table), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load saga %s: %w", id, err)
+	}
+
+	var state State
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ListRunning returns the running sagas.
+func (s *SQLStore) ListRunning(ctx context.Context) ([]*State, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT state FROM %s WHERE status = $1", s.table), string(StatusRunning))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running sagas: %w", err)
+	}
+	defer rows.Close()
+
+	var states []*State
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var state State
+		if err = json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		states = append(states, &state)
+	}
+	return states, rows.Err()
+}
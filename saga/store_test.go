@@ -0,0 +1,314 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sqlRow is a row of the saga table of the fake driver.
+type sqlRow struct {
+	saga    string
+	status  string
+	state   string
+	version int64
+}
+
+// sqlDriver is a database/sql driver emulating the saga table of PostgreSQL. Like PostgreSQL, it rejects
+// binary values for the JSONB state column.
+type sqlDriver struct {
+	mu   sync.Mutex
+	rows map[string]*sqlRow
+}
+
+func (d *sqlDriver) Open(string) (driver.Conn, error) {
+	return &sqlConn{driver: d}, nil
+}
+
+type sqlConn struct {
+	driver *sqlDriver
+}
+
+func (c *sqlConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+
+func (c *sqlConn) Close() error {
+	return nil
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+func (c *sqlConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	d := c.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		state, err := jsonb(args[3].Value)
+		if err != nil {
+			return nil, err
+		}
+		id := args[0].Value.(string)
+		if _, ok := d.rows[id]; ok {
+			return driver.RowsAffected(0), nil
+		}
+		d.rows[id] = &sqlRow{saga: args[1].Value.(string), status: args[2].Value.(string), state: state, version: args[4].Value.(int64)}
+		return driver.RowsAffected(1), nil
+
+	case strings.HasPrefix(query, "UPDATE"):
+		state, err := jsonb(args[2].Value)
+		if err != nil {
+			return nil, err
+		}
+		row, ok := d.rows[args[0].Value.(string)]
+		if !ok || row.version != args[5].Value.(int64) {
+			return driver.RowsAffected(0), nil
+		}
+		row.status, row.state, row.version = args[1].Value.(string), state, args[3].Value.(int64)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+func (c *sqlConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	d := c.driver
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rows := &sqlRows{}
+	for id, row := range d.rows {
+		switch {
+		case strings.HasSuffix(query, "WHERE id = $1") && id == args[0].Value:
+			rows.states = append(rows.states, row.state)
+		case strings.HasSuffix(query, "WHERE status = $1") && row.status == args[0].Value:
+			rows.states = append(rows.states, row.state)
+		}
+	}
+	return rows, nil
+}
+
+// jsonb returns the value of a JSONB parameter, rejecting binary values like PostgreSQL.
+func jsonb(value driver.Value) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("column \"state\" is of type jsonb but expression is of type %T", value)
+	}
+	if !json.Valid([]byte(text)) {
+		return "", errors.New("invalid input syntax for type json")
+	}
+	return text, nil
+}
+
+type sqlRows struct {
+	states []string
+}
+
+func (r *sqlRows) Columns() []string {
+	return []string{"state"}
+}
+
+func (r *sqlRows) Close() error {
+	return nil
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	if len(r.states) == 0 {
+		return io.EOF
+	}
+	dest[0], r.states = []byte(r.states[0]), r.states[1:]
+	return nil
+}
+
+var registerDriver sync.Once
+
+// newSQLStore returns a SQLStore on an empty fake database.
+func newSQLStore(t *testing.T) *SQLStore {
+	t.Helper()
+	registerDriver.Do(func() {
+		sql.Register("sagatest", &sqlDriver{rows: make(map[string]*sqlRow)})
+	})
+
+	db, err := sql.Open("sagatest", "")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	db.Driver().(*sqlDriver).rows = make(map[string]*sqlRow)
+	return NewSQLStore(db, "")
+}
+
+// redisHook emulates the commands of the RedisStore on an in-memory keyspace, without connecting to a server.
+type redisHook struct {
+	mu      sync.Mutex
+	values  map[string]string
+	ttls    map[string]time.Duration
+	members map[string]map[string]bool
+}
+
+func (h *redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("dial not supported")
+	}
+}
+
+func (h *redisHook) ProcessPipelineHook(redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(context.Context, []redis.Cmder) error {
+		return errors.New("pipelines not supported")
+	}
+}
+
+func (h *redisHook) ProcessHook(redis.ProcessHook) redis.ProcessHook {
+	return func(_ context.Context, cmd redis.Cmder) error {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		args := make([]string, len(cmd.Args()))
+		for i, arg := range cmd.Args() {
+			if data, ok := arg.([]byte); ok {
+				args[i] = string(data)
+			} else {
+				args[i] = fmt.Sprint(arg)
+			}
+		}
+
+		switch cmd.Name() {
+		case "evalsha":
+			cmd.(*redis.Cmd).SetVal(h.save(args[3:5], args[5:]))
+		case "get":
+			if value, ok := h.values[args[1]]; ok {
+				cmd.(*redis.StringCmd).SetVal(value)
+			} else {
+				cmd.SetErr(redis.Nil)
+			}
+		case "smembers":
+			var members []string
+			for member := range h.members[args[1]] {
+				members = append(members, member)
+			}
+			cmd.(*redis.StringSliceCmd).SetVal(members)
+		default:
+			cmd.SetErr(fmt.Errorf("unexpected command %s", cmd.Name()))
+		}
+		return cmd.Err()
+	}
+}
+
+// save emulates the saveScript.
+func (h *redisHook) save(keys, argv []string) int64 {
+	var current struct {
+		Version int64 `json:"version"`
+	}
+	if value, ok := h.values[keys[0]]; ok {
+		_ = json.Unmarshal([]byte(value), &current)
+	}
+	if strconv.FormatInt(current.Version, 10) != argv[0] {
+		return 0
+	}
+
+	h.values[keys[0]] = argv[1]
+	ms, _ := strconv.ParseInt(argv[2], 10, 64)
+	h.ttls[keys[0]] = time.Duration(ms) * time.Millisecond
+
+	if h.members[keys[1]] == nil {
+		h.members[keys[1]] = make(map[string]bool)
+	}
+	if argv[3] == "1" {
+		h.members[keys[1]][argv[4]] = true
+	} else {
+		delete(h.members[keys[1]], argv[4])
+	}
+	return 1
+}
+
+// newRedisStore returns a RedisStore on an empty emulated keyspace.
+func newRedisStore(retention time.Duration) (*RedisStore, *redisHook) {
+	hook := &redisHook{
+		values:  make(map[string]string),
+		ttls:    make(map[string]time.Duration),
+		members: make(map[string]map[string]bool),
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	client.AddHook(hook)
+	return &RedisStore{client: client, retention: retention}, hook
+}
+
+// testStore runs a saga through the store: creation, a conflicting stale save, completion and the running list.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	state := &State{Id: "saga-1", Saga: "register_user", Status: StatusRunning, Data: json.RawMessage(`{"email":"john@example.com"}`)}
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Save(ctx, &State{Id: "saga-1", Status: StatusRunning}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save failed: expected %v creating an existing saga but got %v", ErrConflict, err)
+	}
+
+	running, err := store.ListRunning(ctx)
+	if err != nil || len(running) != 1 || running[0].Id != state.Id {
+		t.Fatalf("ListRunning failed: expected saga %s but got %v, %v", state.Id, running, err)
+	}
+
+	stale, err := store.Load(ctx, state.Id)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	state.Status = StatusCompleted
+	if err = store.Save(ctx, state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	stale.Status = StatusCompensating
+	if err = store.Save(ctx, stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save failed: expected %v but got %v", ErrConflict, err)
+	}
+
+	loaded, err := store.Load(ctx, state.Id)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Status != StatusCompleted || loaded.Version != 2 || string(loaded.Data) != string(state.Data) {
+		t.Errorf("Load failed: expected %+v but got %+v", state, loaded)
+	}
+
+	if running, err = store.ListRunning(ctx); err != nil || len(running) != 0 {
+		t.Errorf("ListRunning failed: expected no saga but got %v, %v", running, err)
+	}
+	if _, err = store.Load(ctx, "saga-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load failed: expected %v but got %v", ErrNotFound, err)
+	}
+}
+
+// TestSQLStore is a function to test the SQLStore on a fake PostgreSQL driver.
+func TestSQLStore(t *testing.T) {
+	testStore(t, newSQLStore(t))
+}
+
+// TestRedisStore is a function to test the RedisStore on an emulated keyspace.
+func TestRedisStore(t *testing.T) {
+	store, hook := newRedisStore(time.Hour)
+	testStore(t, store)
+
+	if ttl := hook.ttls[stateKey("saga-1")]; ttl != time.Hour {
+		t.Errorf("Save failed: expected retention %s of the completed saga but got %s", time.Hour, ttl)
+	}
+}
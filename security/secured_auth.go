@@ -3,6 +3,7 @@ package security
 import (
 	"context"
 	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	grpcdomain "github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
 	"github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -15,15 +16,32 @@ func SecuredAuth[Req any, Resp any](
 	req Req,
 	permission domain.Permission,
 	serviceFunc func(context.Context, Req) (Resp, error)) (Resp, error) {
-	principal := util.GetGrpcPrincipal(ctx)
+	principal, err := util.MustPrincipal(ctx)
+	if err != nil {
+		return *new(Resp), err
+	}
 
-	if hasPermission(permission, principal.UserPermission) {
+	if IsGranted(principal, permission) {
 		return serviceFunc(ctx, req)
 	}
 
 	return *new(Resp), accessDenied()
 }
 
+// IsGranted is a function that checks if the principal has the required permission.
+// Service principals need the "resource:action" scope instead.
+func IsGranted(principal *grpcdomain.SweGrpcPrincipal, permission domain.Permission) bool {
+	if principal == nil {
+		return false
+	}
+
+	if principal.IsService() {
+		return hasScope(permission, principal.Scopes)
+	}
+
+	return hasPermission(permission, principal.UserPermission)
+}
+
 // hasPermission checks if the user has the required permission.
 func hasPermission(permissionRequired domain.Permission, userPermission *domain.UserPermission) bool {
 	if userPermission == nil {
@@ -44,6 +62,18 @@ func hasPermission(permissionRequired domain.Permission, userPermission *domain.
 	return false
 }
 
+// hasScope checks if the service has the "resource:action" scope of the required permission.
+func hasScope(permissionRequired domain.Permission, scopes []string) bool {
+	required := permissionRequired.Resource + ":" + permissionRequired.Action
+	for _, scope := range scopes {
+		if strings.EqualFold(scope, required) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // accessDenied returns an error with code PermissionDenied.
 func accessDenied() error {
 	return status.Errorf(codes.PermissionDenied, "Access denied")
@@ -0,0 +1,48 @@
+package security
+
+import (
+	"context"
+	"github.com/ngdangkietswe/swe-go-common-shared/domain"
+	grpcdomain "github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// TestSecuredAuth is a function to test SecuredAuth function.
+func TestSecuredAuth(t *testing.T) {
+	permission := domain.Permission{Resource: "user", Action: "READ"}
+	user := &grpcdomain.SweGrpcPrincipal{
+		Kind:           grpcdomain.PrincipalKindUser,
+		UserId:         "user-1",
+		UserPermission: &domain.UserPermission{Permissions: []*domain.Permission{{Resource: "user", Action: "read"}}},
+	}
+	service := &grpcdomain.SweGrpcPrincipal{Kind: grpcdomain.PrincipalKindService, ClientId: "job", Scopes: []string{"user:read"}}
+	unscoped := &grpcdomain.SweGrpcPrincipal{Kind: grpcdomain.PrincipalKindService, ClientId: "job"}
+
+	tests := []struct {
+		name      string
+		principal *grpcdomain.SweGrpcPrincipal
+		expected  codes.Code
+	}{
+		{"anonymous", nil, codes.Unauthenticated},
+		{"user with permission", user, codes.OK},
+		{"service with scope", service, codes.OK},
+		{"service without scope", unscoped, codes.PermissionDenied},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.principal != nil {
+			ctx = util.WithPrincipal(ctx, test.principal)
+		}
+
+		_, err := SecuredAuth(ctx, "request", permission, func(context.Context, string) (string, error) {
+			return "response", nil
+		})
+		if actual := status.Code(err); actual != test.expected {
+			t.Errorf("SecuredAuth failed for %s: expected %v but got %v", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package token
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter is a probabilistic set answering "definitely absent" or "maybe present".
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+// newBloomFilter sizes a filter for the expected number of items with the given false positive rate.
+func newBloomFilter(expected int, falsePositiveRate float64) *bloomFilter {
+	n := math.Max(float64(expected), 1)
+	size := uint64(math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Max(math.Round(float64(size)/n*math.Ln2), 1))
+
+	return &bloomFilter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// add adds the value to the set.
+func (b *bloomFilter) add(value string) {
+	h1, h2 := hashPair(value)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain returns false if the value was definitely never added.
+func (b *bloomFilter) mayContain(value string) bool {
+	h1, h2 := hashPair(value)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashPair returns the two hashes combined by double hashing.
+func hashPair(value string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	_, _ = h1.Write([]byte(value))
+
+	h2 := fnv.New64()
+	_, _ = h2.Write([]byte(value))
+
+	// An odd second hash never cycles on a single bit.
+	return h1.Sum64(), h2.Sum64() | 1
+}
@@ -0,0 +1,377 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	grpcutil "github.com/ngdangkietswe/swe-go-common-shared/grpc/util"
+	"github.com/ngdangkietswe/swe-go-common-shared/jwks"
+	"github.com/ngdangkietswe/swe-go-common-shared/util"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ClaimFamilyId is the claim carrying the refresh-token family of access and refresh tokens.
+const ClaimFamilyId = constants.ClaimFamilyId
+
+var (
+	// ErrTokenRevoked is returned when a revoked token or a token of a revoked family is presented.
+	ErrTokenRevoked = errors.New("token: revoked")
+
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented. The whole family
+	// is revoked, since either the legitimate client or an attacker holds a stolen token.
+	ErrRefreshTokenReused = errors.New("token: refresh token reused")
+)
+
+// registeredClaims are the claims set on every token, not carried over on refresh.
+var registeredClaims = map[string]bool{
+	"jti": true, "typ": true, "sub": true, "user": true, "iat": true, "nbf": true, "exp": true,
+	"iss": true, "aud": true, ClaimFamilyId: true,
+}
+
+// TokenPair is an access token and the refresh token to renew it.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	FamilyId     string `json:"family_id"`
+}
+
+// Service issues, rotates and revokes tokens. Revoked jti and families are kept in a denylist checked through an
+// in-process bloom filter synced by Run, so that most checks never reach the store. Until the first sync, or when
+// syncing stalled for more than twice the sync interval, every check reaches the store.
+type Service struct {
+	store        Store
+	keySet       *jwks.KeySet
+	keyfunc      jwt.Keyfunc
+	validation   util.ValidationOptions
+	syncInterval time.Duration
+	capacity     int
+
+	mu       sync.RWMutex
+	bloom    *bloomFilter
+	syncedAt time.Time
+}
+
+// Option defines a function type for configuring the Service.
+type Option func(*Service)
+
+// WithKeySet signs the tokens with the active key of the key set instead of JWT_SECRET.
+func WithKeySet(keySet *jwks.KeySet) Option {
+	return func(s *Service) {
+		s.keySet = keySet
+		s.keyfunc = keySet.Keyfunc
+	}
+}
+
+// WithValidation sets the issuer, audience and leeway checks of presented tokens.
+func WithValidation(validation util.ValidationOptions) Option {
+	return func(s *Service) {
+		s.validation = validation
+	}
+}
+
+// WithSyncInterval sets how often the bloom filter is rebuilt from the store, bounding how long a revocation
+// made by another instance goes unnoticed.
+func WithSyncInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.syncInterval = interval
+	}
+}
+
+// NewService creates a token service configured from the JWT_* and TOKEN_DENYLIST_* config keys.
+func NewService(store Store, options ...Option) *Service {
+	service := &Service{
+		store:        store,
+		keyfunc:      util.HMACKeyfunc(config.GetString("JWT_SECRET", "")),
+		validation:   util.DefaultValidationOptions(),
+		syncInterval: time.Duration(config.GetInt("TOKEN_DENYLIST_SYNC_SECONDS", 10)) * time.Second,
+		capacity:     config.GetInt("TOKEN_DENYLIST_CAPACITY", 100000),
+	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(service)
+	}
+
+	service.bloom = newBloomFilter(service.capacity, 0.01)
+	return service
+}
+
+// Issue starts a new refresh-token family for the user and returns its first token pair.
+func (s *Service) Issue(ctx context.Context, grpcUser *domain.GrpcUser, options ...util.TokenOption) (*TokenPair, error) {
+	familyId := uuid.NewString()
+
+	pair, refreshJti, accessJti, err := s.generate(grpcUser, familyId, options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.CreateFamily(ctx, Family{
+		Id:        familyId,
+		UserId:    grpcUser.Id,
+		Current:   refreshJti,
+		Access:    accessJti,
+		ExpiresAt: time.Now().Add(refreshExpiration()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// Refresh exchanges the current refresh token of a family for a new pair. Presenting a rotated refresh token
+// revokes the whole family.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	validation := s.validation
+	validation.TokenType = constants.TokenTypeRefresh
+
+	claims, err := util.ValidateToken(refreshToken, s.keyfunc, validation)
+	if err != nil {
+		return nil, err
+	}
+
+	jti, _ := (*claims)["jti"].(string)
+	familyId, _ := (*claims)[ClaimFamilyId].(string)
+
+	if revoked, err := s.IsRevoked(ctx, jti, familyId); err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	family, err := s.store.LoadFamily(ctx, familyId)
+	if errors.Is(err, ErrFamilyNotFound) {
+		return nil, ErrTokenRevoked
+	} else if err != nil {
+		return nil, err
+	}
+
+	if family.Revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	if family.Current != jti {
+		return nil, s.reused(ctx, familyId)
+	}
+
+	principal, err := grpcutil.AsGrpcPrincipal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user claims: %w", err)
+	}
+
+	// Carry over the custom claims, such as roles and scopes.
+	var options []util.TokenOption
+	for key, value := range *claims {
+		if !registeredClaims[key] {
+			options = append(options, util.WithClaim(key, value))
+		}
+	}
+
+	grpcUser := &domain.GrpcUser{Id: principal.UserId, Username: principal.Username, Email: principal.Email}
+	pair, refreshJti, accessJti, err := s.generate(grpcUser, familyId, options)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.store.RotateFamily(ctx, familyId, jti, refreshJti, accessJti, time.Now().Add(refreshExpiration()))
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent refresh rotated the same token first.
+	if !rotated {
+		return nil, s.reused(ctx, familyId)
+	}
+
+	return pair, nil
+}
+
+// Revoke revokes a token until its expiry, e.g. on logout. Revoking a refresh token revokes its family.
+// Expired tokens are ignored.
+func (s *Service) Revoke(ctx context.Context, token string) error {
+	validation := s.validation
+	validation.TokenType = ""
+
+	claims, err := util.ValidateToken(token, s.keyfunc, validation)
+	if errors.Is(err, util.ErrTokenExpired) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	jti, _ := (*claims)["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return fmt.Errorf("token has no expiration")
+	}
+
+	if err = s.deny(ctx, jti, exp.Time); err != nil {
+		return err
+	}
+
+	if tokenType, _ := (*claims)["typ"].(string); tokenType == constants.TokenTypeRefresh {
+		familyId, _ := (*claims)[ClaimFamilyId].(string)
+		return s.RevokeFamily(ctx, familyId)
+	}
+	return nil
+}
+
+// RevokeFamily revokes a refresh-token family with every refresh and access token issued in it. The family ID
+// is denied until the last of its tokens expires.
+func (s *Service) RevokeFamily(ctx context.Context, familyId string) error {
+	family, err := s.store.RevokeFamily(ctx, familyId)
+	if errors.Is(err, ErrFamilyNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	until := time.Now().Add(accessExpiration())
+	if family.ExpiresAt.After(until) {
+		until = family.ExpiresAt
+	}
+	return s.deny(ctx, familyDenyKey(familyId), until)
+}
+
+// IsRevoked reports whether the jti or the refresh-token family of a token is denied, the family ID being empty
+// for tokens issued outside a family. Only entries possibly in the bloom filter are checked in the store, unless
+// the bloom filter is stale and may miss the revocations of other instances.
+func (s *Service) IsRevoked(ctx context.Context, jti, familyId string) (bool, error) {
+	for _, key := range []string{jti, familyDenyKey(familyId)} {
+		if key == "" {
+			continue
+		}
+
+		s.mu.RLock()
+		mayContain := time.Since(s.syncedAt) > 2*s.syncInterval || s.bloom.mayContain(key)
+		s.mu.RUnlock()
+
+		if !mayContain {
+			continue
+		}
+		if denied, err := s.store.IsDenied(ctx, key); err != nil || denied {
+			return denied, err
+		}
+	}
+	return false, nil
+}
+
+// SyncDenylist rebuilds the bloom filter from the denylist of the store, picking up the revocations of other
+// instances and dropping the expired ones.
+func (s *Service) SyncDenylist(ctx context.Context) error {
+	jtis, err := s.store.Denylist(ctx)
+	if err != nil {
+		return err
+	}
+
+	bloom := newBloomFilter(max(s.capacity, len(jtis)), 0.01)
+	for _, jti := range jtis {
+		bloom.add(jti)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bloom = bloom
+	s.syncedAt = time.Now()
+	return nil
+}
+
+// Run syncs the denylist at every sync interval until the context is done. Without it, every revocation check
+// reaches the store.
+func (s *Service) Run(ctx context.Context) {
+	if err := s.SyncDenylist(ctx); err != nil {
+		log.Printf("Error while syncing token denylist: %v", err)
+	}
+
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SyncDenylist(ctx); err != nil {
+				log.Printf("Error while syncing token denylist: %v", err)
+			}
+		}
+	}
+}
+
+// reused revokes the family of a reused refresh token.
+func (s *Service) reused(ctx context.Context, familyId string) error {
+	log.Printf("Refresh token reuse detected, revoking token family %s", familyId)
+	if err := s.RevokeFamily(ctx, familyId); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// familyDenyKey returns the denylist entry of a refresh-token family, distinct from the jti entries.
+func familyDenyKey(familyId string) string {
+	if familyId == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", ClaimFamilyId, familyId)
+}
+
+// deny adds the jti to the denylist of the store and the bloom filter.
+func (s *Service) deny(ctx context.Context, jti string, until time.Time) error {
+	if jti == "" {
+		return nil
+	}
+
+	if err := s.store.Deny(ctx, jti, until); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bloom.add(jti)
+	return nil
+}
+
+// generate signs a token pair of the family, returning the jti of the refresh and access tokens.
+func (s *Service) generate(grpcUser *domain.GrpcUser, familyId string, options []util.TokenOption) (*TokenPair, string, string, error) {
+	accessJti, refreshJti := uuid.NewString(), uuid.NewString()
+
+	accessToken, err := s.sign(grpcUser, false, slices.Concat(options, []util.TokenOption{
+		util.WithClaim("jti", accessJti), util.WithClaim(ClaimFamilyId, familyId),
+	}))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	refreshToken, err := s.sign(grpcUser, true, slices.Concat(options, []util.TokenOption{
+		util.WithClaim("jti", refreshJti), util.WithClaim(ClaimFamilyId, familyId),
+	}))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, FamilyId: familyId}, refreshJti, accessJti, nil
+}
+
+// sign generates a token with the key set or JWT_SECRET.
+func (s *Service) sign(grpcUser *domain.GrpcUser, isRefresh bool, options []util.TokenOption) (string, error) {
+	if s.keySet != nil {
+		return util.GenerateTokenWithKeySet(s.keySet, grpcUser, isRefresh, options...)
+	}
+	return util.GenerateToken(grpcUser, isRefresh, options...)
+}
+
+func accessExpiration() time.Duration {
+	return time.Second * time.Duration(config.GetInt("JWT_EXPIRATION", 3600))
+}
+
+func refreshExpiration() time.Duration {
+	return time.Second * time.Duration(config.GetInt("REFRESH_TOKEN_EXPIRATION", 7200))
+}
@@ -0,0 +1,139 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/util"
+	"github.com/spf13/viper"
+	"testing"
+)
+
+func newTestService(t *testing.T) (*Service, *MemoryStore) {
+	t.Helper()
+	viper.Set("JWT_SECRET", "test-secret")
+
+	store := NewMemoryStore()
+	return NewService(store, WithValidation(util.ValidationOptions{})), store
+}
+
+// jtiOf returns the jti of a token.
+func jtiOf(t *testing.T, token string) string {
+	t.Helper()
+	claims, err := util.ParseToken(token, "test-secret")
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	return (*claims)["jti"].(string)
+}
+
+// TestServiceRefresh is a function to test refresh-token rotation and reuse detection.
+func TestServiceRefresh(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := context.Background()
+
+	first, err := service.Issue(ctx, &domain.GrpcUser{Id: "user-1", Username: "john"}, util.WithClaim("roles", []string{"admin"}))
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	second, err := service.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if second.FamilyId != first.FamilyId {
+		t.Errorf("Refresh failed: expected family %s but got %s", first.FamilyId, second.FamilyId)
+	}
+
+	claims, _ := util.ParseToken(second.AccessToken, "test-secret")
+	if fmt.Sprint((*claims)["roles"]) != "[admin]" {
+		t.Errorf("Refresh failed: expected custom claims to be carried over but got %v", *claims)
+	}
+
+	// Presenting the rotated refresh token revokes the family.
+	if _, err = service.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("Refresh failed: expected %v but got %v", ErrRefreshTokenReused, err)
+	}
+	if _, err = service.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh failed: expected %v but got %v", ErrTokenRevoked, err)
+	}
+	for name, token := range map[string]string{"first": first.AccessToken, "latest": second.AccessToken} {
+		if revoked, _ := service.IsRevoked(ctx, jtiOf(t, token), first.FamilyId); !revoked {
+			t.Errorf("Refresh failed: expected the %s access token of the family to be revoked", name)
+		}
+	}
+
+	// An access token cannot be used as a refresh token.
+	if _, err = service.Refresh(ctx, second.AccessToken); !errors.Is(err, util.ErrTokenInvalidType) {
+		t.Errorf("Refresh failed: expected %v but got %v", util.ErrTokenInvalidType, err)
+	}
+}
+
+// TestServiceRevoke is a function to test the jti denylist.
+func TestServiceRevoke(t *testing.T) {
+	service, store := newTestService(t)
+	ctx := context.Background()
+
+	pair, _ := service.Issue(ctx, &domain.GrpcUser{Id: "user-1"})
+	other, _ := service.Issue(ctx, &domain.GrpcUser{Id: "user-2"})
+
+	if err := service.Revoke(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	if revoked, _ := service.IsRevoked(ctx, jtiOf(t, pair.AccessToken), pair.FamilyId); !revoked {
+		t.Errorf("IsRevoked failed: expected the revoked access token to be denied")
+	}
+	if revoked, _ := service.IsRevoked(ctx, jtiOf(t, other.AccessToken), other.FamilyId); revoked {
+		t.Errorf("IsRevoked failed: expected another access token not to be denied")
+	}
+
+	// A revocation made by another instance is seen before the first sync, the store being checked directly.
+	instance := NewService(store, WithValidation(util.ValidationOptions{}))
+	if revoked, _ := instance.IsRevoked(ctx, jtiOf(t, pair.AccessToken), pair.FamilyId); !revoked {
+		t.Errorf("IsRevoked failed: expected the revoked access token to be denied before a sync")
+	}
+
+	// Once synced, it is seen through the bloom filter.
+	_ = instance.SyncDenylist(ctx)
+	third, _ := service.Issue(ctx, &domain.GrpcUser{Id: "user-3"})
+	_ = service.Revoke(ctx, third.AccessToken)
+	_ = instance.SyncDenylist(ctx)
+	if revoked, _ := instance.IsRevoked(ctx, jtiOf(t, third.AccessToken), third.FamilyId); !revoked {
+		t.Errorf("IsRevoked failed: expected the revoked access token to be denied after a sync")
+	}
+
+	// Logging out with the refresh token revokes the family.
+	if err := service.Revoke(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := service.Refresh(ctx, other.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh failed: expected %v but got %v", ErrTokenRevoked, err)
+	}
+	if revoked, _ := service.IsRevoked(ctx, jtiOf(t, other.AccessToken), other.FamilyId); !revoked {
+		t.Errorf("IsRevoked failed: expected the access token of the revoked family to be denied")
+	}
+}
+
+// TestBloomFilter is a function to test the false positive rate of bloomFilter.
+func TestBloomFilter(t *testing.T) {
+	bloom := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		bloom.add(fmt.Sprintf("added-%d", i))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if !bloom.mayContain(fmt.Sprintf("added-%d", i%1000)) {
+			t.Fatalf("mayContain failed: expected added-%d to be present", i%1000)
+		}
+		if bloom.mayContain(fmt.Sprintf("absent-%d", i)) {
+			falsePositives++
+		}
+	}
+
+	if falsePositives > 300 {
+		t.Errorf("mayContain failed: expected about 1%% false positives but got %d of 10000", falsePositives)
+	}
+}
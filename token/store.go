@@ -0,0 +1,269 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ngdangkietswe/swe-go-common-shared/cache"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrFamilyNotFound is returned when a refresh-token family does not exist or expired.
+var ErrFamilyNotFound = errors.New("token: family not found")
+
+// Family is a chain of rotated refresh tokens issued from a single login. Only its current refresh token
+// can be exchanged; presenting an older one reveals a stolen token.
+type Family struct {
+	Id        string
+	UserId    string
+	Current   string
+	Access    string
+	Revoked   bool
+	ExpiresAt time.Time
+}
+
+// Store persists the refresh-token families and the jti denylist.
+type Store interface {
+	CreateFamily(ctx context.Context, family Family) error
+	LoadFamily(ctx context.Context, id string) (*Family, error)
+
+	// RotateFamily replaces the current refresh token if it is still currentJti and the family is not revoked.
+	// Returns false otherwise.
+	RotateFamily(ctx context.Context, id, currentJti, refreshJti, accessJti string, expiresAt time.Time) (bool, error)
+
+	// RevokeFamily marks the family as revoked and returns it.
+	RevokeFamily(ctx context.Context, id string) (*Family, error)
+
+	// Deny adds the jti to the denylist until the given time, usually the token expiry.
+	Deny(ctx context.Context, jti string, until time.Time) error
+	IsDenied(ctx context.Context, jti string) (bool, error)
+
+	// Denylist returns the denied jti not yet expired.
+	Denylist(ctx context.Context) ([]string, error)
+}
+
+// rotateScript swaps the current refresh token of a family that is not revoked.
+var rotateScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'revoked') ~= '0' or redis.call('HGET', KEYS[1], 'current') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'current', ARGV[2], 'access', ARGV[3], 'expires_at', ARGV[4])
+redis.call('PEXPIREAT', KEYS[1], ARGV[4])
+return 1`)
+
+// revokeScript marks an existing family as revoked.
+var revokeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'revoked', '1')
+return 1`)
+
+// RedisStore is a Store sharing the connection of the cache. Families are hashes under token_family:<id>
+// expiring with their refresh token, and the denylist is the token_denylist sorted set scored by expiry.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(redisCache *cache.RedisCache) *RedisStore {
+	return &RedisStore{client: redisCache.Client()}
+}
+
+// CreateFamily stores a new family.
+func (s *RedisStore) CreateFamily(ctx context.Context, family Family) error {
+	key := familyKey(family.Id)
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key,
+		"user_id", family.UserId,
+		"current", family.Current,
+		"access", family.Access,
+		"revoked", "0",
+		"expires_at", family.ExpiresAt.UnixMilli(),
+	)
+	pipe.PExpireAt(ctx, key, family.ExpiresAt)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to create token family %s: %w", family.Id, err)
+	}
+	return nil
+}
+
+// LoadFamily returns the family.
+func (s *RedisStore) LoadFamily(ctx context.Context, id string) (*Family, error) {
+	fields, err := s.client.HGetAll(ctx, familyKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token family %s: %w", id, err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrFamilyNotFound
+	}
+
+	expiresAt, _ := strconv.ParseInt(fields["expires_at"], 10, 64)
+	return &Family{
+		Id:        id,
+		UserId:    fields["user_id"],
+		Current:   fields["current"],
+		Access:    fields["access"],
+		Revoked:   fields["revoked"] == "1",
+		ExpiresAt: time.UnixMilli(expiresAt),
+	}, nil
+}
+
+// RotateFamily atomically swaps the current refresh token.
+func (s *RedisStore) RotateFamily(ctx context.Context, id, currentJti, refreshJti, accessJti string, expiresAt time.Time) (bool, error) {
+	rotated, err := rotateScript.Run(ctx, s.client, []string{familyKey(id)},
+		currentJti, refreshJti, accessJti, expiresAt.UnixMilli()).Bool()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate token family %s: %w", id, err)
+	}
+	return rotated, nil
+}
+
+// RevokeFamily marks the family as revoked.
+func (s *RedisStore) RevokeFamily(ctx context.Context, id string) (*Family, error) {
+	revoked, err := revokeScript.Run(ctx, s.client, []string{familyKey(id)}).Bool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke token family %s: %w", id, err)
+	}
+	if !revoked {
+		return nil, ErrFamilyNotFound
+	}
+	return s.LoadFamily(ctx, id)
+}
+
+// Deny adds the jti to the denylist.
+func (s *RedisStore) Deny(ctx context.Context, jti string, until time.Time) error {
+	err := s.client.ZAdd(ctx, constants.TokenDenylistCacheKey, redis.Z{Score: float64(until.UnixMilli()), Member: jti}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to deny token %s: %w", jti, err)
+	}
+	return nil
+}
+
+// IsDenied reports whether the jti is denied and not yet expired.
+func (s *RedisStore) IsDenied(ctx context.Context, jti string) (bool, error) {
+	score, err := s.client.ZScore(ctx, constants.TokenDenylistCacheKey, jti).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to check denied token %s: %w", jti, err)
+	}
+	return int64(score) > time.Now().UnixMilli(), nil
+}
+
+// Denylist drops the expired entries and returns the remaining ones.
+func (s *RedisStore) Denylist(ctx context.Context) ([]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := s.client.ZRemRangeByScore(ctx, constants.TokenDenylistCacheKey, "-inf", now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to purge token denylist: %w", err)
+	}
+	return s.client.ZRange(ctx, constants.TokenDenylistCacheKey, 0, -1).Result()
+}
+
+func familyKey(id string) string {
+	return fmt.Sprintf("%s:%s", constants.TokenFamilyCacheKeyPrefix, id)
+}
+
+// MemoryStore is an in-process Store, suitable for tests and single instance deployments.
+type MemoryStore struct {
+	mu       sync.Mutex
+	families map[string]Family
+	denied   map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		families: make(map[string]Family),
+		denied:   make(map[string]time.Time),
+	}
+}
+
+// CreateFamily stores a new family.
+func (s *MemoryStore) CreateFamily(_ context.Context, family Family) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.families[family.Id] = family
+	return nil
+}
+
+// LoadFamily returns the family.
+func (s *MemoryStore) LoadFamily(_ context.Context, id string) (*Family, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	family, ok := s.families[id]
+	if !ok || !time.Now().Before(family.ExpiresAt) {
+		return nil, ErrFamilyNotFound
+	}
+	return &family, nil
+}
+
+// RotateFamily swaps the current refresh token.
+func (s *MemoryStore) RotateFamily(_ context.Context, id, currentJti, refreshJti, accessJti string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	family, ok := s.families[id]
+	if !ok || family.Revoked || family.Current != currentJti {
+		return false, nil
+	}
+
+	family.Current = refreshJti
+	family.Access = accessJti
+	family.ExpiresAt = expiresAt
+	s.families[id] = family
+	return true, nil
+}
+
+// RevokeFamily marks the family as revoked.
+func (s *MemoryStore) RevokeFamily(_ context.Context, id string) (*Family, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	family, ok := s.families[id]
+	if !ok {
+		return nil, ErrFamilyNotFound
+	}
+
+	family.Revoked = true
+	s.families[id] = family
+	return &family, nil
+}
+
+// Deny adds the jti to the denylist.
+func (s *MemoryStore) Deny(_ context.Context, jti string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[jti] = until
+	return nil
+}
+
+// IsDenied reports whether the jti is denied and not yet expired.
+func (s *MemoryStore) IsDenied(_ context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.denied[jti]
+	return ok && time.Now().Before(until), nil
+}
+
+// Denylist drops the expired entries and returns the remaining ones.
+func (s *MemoryStore) Denylist(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	jtis := make([]string, 0, len(s.denied))
+	for jti, until := range s.denied {
+		if !now.Before(until) {
+			delete(s.denied, jti)
+			continue
+		}
+		jtis = append(jtis, jti)
+	}
+	return jtis, nil
+}
@@ -1,8 +1,16 @@
 package util
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	sharedomain "github.com/ngdangkietswe/swe-go-common-shared/domain"
 )
 
 // GenerateSecureToken generates a secure token.
@@ -13,3 +21,57 @@ func GenerateSecureToken() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
+
+// SignUserPermission is a function that returns the permission payload of the grpc-user-permission metadata and its
+// signature for the grpc-user-permission-signature metadata. The signature is bound to the sub and exp claims of the
+// token, so the payload cannot be replayed with another user or outlive the token.
+func SignUserPermission(userPermission *sharedomain.UserPermission, claims *jwt.MapClaims, secret string) (string, string, error) {
+	if secret == "" {
+		return "", "", errors.New("missing permission signing secret")
+	}
+
+	payload, err := json.Marshal(userPermission)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal user permission: %w", err)
+	}
+
+	signature, err := permissionSignature(payload, claims, secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(payload), signature, nil
+}
+
+// VerifyUserPermission is a function that checks the signature of a permission payload against the token claims.
+func VerifyUserPermission(payload, signature string, claims *jwt.MapClaims, secret string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	expected, err := permissionSignature([]byte(payload), claims, secret)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// permissionSignature returns the base64url HMAC-SHA256 of the sub and exp claims and the payload.
+func permissionSignature(payload []byte, claims *jwt.MapClaims, secret string) (string, error) {
+	sub, err := claims.GetSubject()
+	if err != nil || sub == "" {
+		return "", errors.New("token has no subject")
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return "", errors.New("token has no expiration time")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s.%d.", sub, exp.Unix())
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
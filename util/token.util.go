@@ -1,21 +1,138 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/ngdangkietswe/swe-go-common-shared/config"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	sharedomain "github.com/ngdangkietswe/swe-go-common-shared/domain"
 	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/jwks"
+	"sort"
+	"strings"
 	"time"
 )
 
+// Token validation errors. They wrap the underlying jwt error, so errors.Is matches both.
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenInvalidIssuer    = errors.New("token has invalid issuer")
+	ErrTokenInvalidAudience  = errors.New("token has invalid audience")
+	ErrTokenInvalidType      = errors.New("token has invalid type")
+)
+
+// ValidationOptions are the claims checked by ValidateToken on top of the signature.
+type ValidationOptions struct {
+	// Issuer is the expected iss claim. Empty skips the check.
+	Issuer string
+
+	// Audience must be one of the aud claim values. Empty skips the check.
+	Audience string
+
+	// Leeway is the clock skew tolerated on exp, nbf and iat.
+	Leeway time.Duration
+
+	// TokenType is the expected typ claim, TokenTypeAccess or TokenTypeRefresh. Empty skips the check.
+	TokenType string
+}
+
 type JwtUserClaims struct {
 	UserId   string `json:"user_id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
 }
 
+// TokenOption defines a function type for customizing the claims of a generated token.
+type TokenOption func(claims jwt.MapClaims)
+
+// WithClaim sets a claim of the generated token, overriding the default one.
+func WithClaim(key string, value interface{}) TokenOption {
+	return func(claims jwt.MapClaims) {
+		claims[key] = value
+	}
+}
+
+// WithRoles sets the roles claim, filled into SweGrpcPrincipal.Roles.
+func WithRoles(roles ...string) TokenOption {
+	return WithClaim(constants.ClaimRoles, roles)
+}
+
+// WithScopes sets the space separated scope claim.
+func WithScopes(scopes ...string) TokenOption {
+	return WithClaim(constants.ClaimScope, strings.Join(scopes, " "))
+}
+
+// WithPermissionsVersion sets the pv claim, the version of the user permissions the token was issued with.
+func WithPermissionsVersion(version string) TokenOption {
+	return WithClaim(constants.ClaimPermissionsVersion, version)
+}
+
+// WithPermissionsHash sets the ph claim to the PermissionsHash of the user permissions, binding the token
+// to the permissions propagated alongside it.
+func WithPermissionsHash(userPermission *sharedomain.UserPermission) TokenOption {
+	return WithClaim(constants.ClaimPermissionsHash, PermissionsHash(userPermission))
+}
+
+// PermissionsHash is a function that returns the SHA-256 of the permissions, independent of their order and case.
+func PermissionsHash(userPermission *sharedomain.UserPermission) string {
+	var entries []string
+	if userPermission != nil {
+		for _, permission := range userPermission.Permissions {
+			if permission != nil {
+				entries = append(entries, strings.ToLower(permission.Resource)+":"+strings.ToLower(permission.Action))
+			}
+		}
+	}
+	sort.Strings(entries)
+
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
 // GenerateToken is a function that generates a JWT token.
-func GenerateToken(grpcUser *domain.GrpcUser, isRefresh bool) (string, error) {
+func GenerateToken(grpcUser *domain.GrpcUser, isRefresh bool, options ...TokenOption) (string, error) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, userClaims(grpcUser, isRefresh, options...)).SignedString([]byte(config.GetString("JWT_SECRET", "")))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GenerateTokenWithKeySet is a function that generates a JWT token signed by the active key of the key set,
+// with its kid header.
+func GenerateTokenWithKeySet(keySet *jwks.KeySet, grpcUser *domain.GrpcUser, isRefresh bool, options ...TokenOption) (string, error) {
+	return keySet.Sign(userClaims(grpcUser, isRefresh, options...))
+}
+
+// GenerateServiceToken is a function that generates the access token of a service identity, authorized by its scopes
+// rather than user permissions. It expires after SERVICE_TOKEN_EXPIRATION seconds.
+func GenerateServiceToken(clientId string, scopes []string, options ...TokenOption) (string, error) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, serviceClaims(clientId, scopes, options...)).SignedString([]byte(config.GetString("JWT_SECRET", "")))
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GenerateServiceTokenWithKeySet is a function that generates the access token of a service identity signed by the
+// active key of the key set.
+func GenerateServiceTokenWithKeySet(keySet *jwks.KeySet, clientId string, scopes []string, options ...TokenOption) (string, error) {
+	return keySet.Sign(serviceClaims(clientId, scopes, options...))
+}
+
+// userClaims builds the claims of a user token.
+func userClaims(grpcUser *domain.GrpcUser, isRefresh bool, options ...TokenOption) jwt.MapClaims {
 	var tokenExp time.Duration
 	if isRefresh {
 		tokenExp = time.Second * time.Duration(config.GetInt("REFRESH_TOKEN_EXPIRATION", 7200))
@@ -23,46 +140,163 @@ func GenerateToken(grpcUser *domain.GrpcUser, isRefresh bool) (string, error) {
 		tokenExp = time.Second * time.Duration(config.GetInt("JWT_EXPIRATION", 3600))
 	}
 
-	exp := time.Now().Add(tokenExp).Unix()
+	tokenType := constants.TokenTypeAccess
+	if isRefresh {
+		tokenType = constants.TokenTypeRefresh
+	}
 
-	mapClaims := make(jwt.MapClaims)
-	mapClaims["sub"] = grpcUser.Id
+	mapClaims := baseClaims(grpcUser.Id, tokenType, tokenExp)
 	mapClaims["user"] = JwtUserClaims{
 		UserId:   grpcUser.Id,
 		Username: grpcUser.Username,
 		Email:    grpcUser.Email,
 	}
+
+	// Apply custom options
+	for _, option := range options {
+		option(mapClaims)
+	}
+
+	return mapClaims
+}
+
+// serviceClaims builds the claims of a service token.
+func serviceClaims(clientId string, scopes []string, options ...TokenOption) jwt.MapClaims {
+	tokenExp := time.Second * time.Duration(config.GetInt("SERVICE_TOKEN_EXPIRATION", 3600))
+
+	mapClaims := baseClaims(clientId, constants.TokenTypeAccess, tokenExp)
+	mapClaims[constants.ClaimClientId] = clientId
+	mapClaims[constants.ClaimTokenUse] = constants.TokenUseService
+	WithScopes(scopes...)(mapClaims)
+
+	// Apply custom options
+	for _, option := range options {
+		option(mapClaims)
+	}
+
+	return mapClaims
+}
+
+// baseClaims builds the registered claims shared by user and service tokens.
+func baseClaims(sub, tokenType string, tokenExp time.Duration) jwt.MapClaims {
+	exp := time.Now().Add(tokenExp).Unix()
+
+	mapClaims := make(jwt.MapClaims)
+	mapClaims["jti"] = uuid.NewString()
+	mapClaims["typ"] = tokenType
+	mapClaims["sub"] = sub
 	mapClaims["iat"] = time.Now().Unix()
 	mapClaims["nbf"] = time.Now().Unix()
 	mapClaims["exp"] = exp
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, mapClaims).SignedString([]byte(config.GetString("JWT_SECRET", "")))
+	if issuer := config.GetString("JWT_ISSUER", ""); issuer != "" {
+		mapClaims["iss"] = issuer
+	}
 
-	if err != nil {
-		return "", err
+	if audience := splitList(config.GetString("JWT_AUDIENCE", "")); len(audience) > 0 {
+		mapClaims["aud"] = audience
 	}
 
-	return token, nil
+	return mapClaims
+}
+
+// DefaultValidationOptions returns the validation options of access tokens from the JWT_ISSUER, JWT_AUDIENCE
+// and JWT_LEEWAY_SECONDS config keys. With several audiences configured, the first one is expected.
+func DefaultValidationOptions() ValidationOptions {
+	options := ValidationOptions{
+		Issuer:    config.GetString("JWT_ISSUER", ""),
+		Leeway:    time.Duration(config.GetInt("JWT_LEEWAY_SECONDS", 0)) * time.Second,
+		TokenType: constants.TokenTypeAccess,
+	}
+
+	if audience := splitList(config.GetString("JWT_AUDIENCE", "")); len(audience) > 0 {
+		options.Audience = audience[0]
+	}
+
+	return options
 }
 
 // ParseToken is a function that parses a JWT token.
 func ParseToken(jwtToken, jwtSecret string) (*jwt.MapClaims, error) {
-	token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
+	return ParseTokenWithKeyfunc(jwtToken, HMACKeyfunc(jwtSecret))
+}
+
+// ParseTokenWithKeyfunc is a function that parses a JWT token verified by the key of the keyfunc,
+// e.g. jwks.KeySet.Keyfunc or jwks.Loader.Keyfunc for asymmetric tokens.
+func ParseTokenWithKeyfunc(jwtToken string, keyfunc jwt.Keyfunc) (*jwt.MapClaims, error) {
+	return ValidateToken(jwtToken, keyfunc, ValidationOptions{})
+}
+
+// ValidateToken is a function that parses a JWT token and checks its claims against the options.
+// Errors wrap one of the ErrToken* errors.
+func ValidateToken(jwtToken string, keyfunc jwt.Keyfunc, options ValidationOptions) (*jwt.MapClaims, error) {
+	parserOptions := []jwt.ParserOption{jwt.WithLeeway(options.Leeway)}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+
+	token, err := jwt.Parse(jwtToken, keyfunc, parserOptions...)
 
 	if err != nil {
-		return nil, err
+		return nil, classifyTokenError(err)
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 
 	if !token.Valid || !ok {
-		return nil, err
+		return nil, ErrTokenMalformed
+	}
+
+	if options.TokenType != "" {
+		if tokenType, _ := claims["typ"].(string); tokenType != options.TokenType {
+			return nil, fmt.Errorf("%w: expected %s but got %q", ErrTokenInvalidType, options.TokenType, tokenType)
+		}
 	}
 
 	return &claims, nil
 }
+
+// HMACKeyfunc is a function that returns a jwt.Keyfunc verifying HMAC tokens with the secret.
+func HMACKeyfunc(jwtSecret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	}
+}
+
+// classifyTokenError wraps a jwt parsing error with the matching ErrToken* error.
+func classifyTokenError(err error) error {
+	for _, mapping := range []struct {
+		jwtErr error
+		err    error
+	}{
+		{jwt.ErrTokenExpired, ErrTokenExpired},
+		{jwt.ErrTokenNotValidYet, ErrTokenNotValidYet},
+		{jwt.ErrTokenUsedBeforeIssued, ErrTokenNotValidYet},
+		{jwt.ErrTokenInvalidIssuer, ErrTokenInvalidIssuer},
+		{jwt.ErrTokenInvalidAudience, ErrTokenInvalidAudience},
+		{jwt.ErrTokenSignatureInvalid, ErrTokenSignatureInvalid},
+		{jwt.ErrTokenUnverifiable, ErrTokenSignatureInvalid},
+	} {
+		if errors.Is(err, mapping.jwtErr) {
+			return fmt.Errorf("%w: %w", mapping.err, err)
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+}
+
+// splitList splits a comma separated config value, trimming spaces.
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,142 @@
+package util
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ngdangkietswe/swe-go-common-shared/constants"
+	sharedomain "github.com/ngdangkietswe/swe-go-common-shared/domain"
+	"github.com/ngdangkietswe/swe-go-common-shared/grpc/domain"
+	"github.com/spf13/viper"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// TestGenerateToken is a function to test the claims of GenerateToken function.
+func TestGenerateToken(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+	viper.Set("JWT_ISSUER", "swe-auth")
+	viper.Set("JWT_AUDIENCE", "swe-api, swe-admin")
+	defer viper.Set("JWT_ISSUER", "")
+	defer viper.Set("JWT_AUDIENCE", "")
+
+	access, _ := GenerateToken(&domain.GrpcUser{Id: "user-1"}, false)
+	other, _ := GenerateToken(&domain.GrpcUser{Id: "user-1"}, false)
+
+	claims, err := ValidateToken(access, HMACKeyfunc(testSecret), DefaultValidationOptions())
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+
+	otherClaims, _ := ParseToken(other, testSecret)
+	if (*claims)["jti"] == "" || (*claims)["jti"] == (*otherClaims)["jti"] {
+		t.Errorf("GenerateToken failed: expected unique jti but got %v and %v", (*claims)["jti"], (*otherClaims)["jti"])
+	}
+	if (*claims)["typ"] != constants.TokenTypeAccess || (*claims)["iss"] != "swe-auth" {
+		t.Errorf("GenerateToken failed: unexpected claims %v", *claims)
+	}
+}
+
+// TestValidateToken is a function to test the typed errors of ValidateToken function.
+func TestValidateToken(t *testing.T) {
+	viper.Set("JWT_SECRET", testSecret)
+	refresh, _ := GenerateToken(&domain.GrpcUser{Id: "user-1"}, true)
+
+	sign := func(claims jwt.MapClaims) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+		return token
+	}
+
+	now := time.Now()
+	options := ValidationOptions{Issuer: "swe-auth", Audience: "swe-api", TokenType: constants.TokenTypeAccess}
+	valid := jwt.MapClaims{"iss": "swe-auth", "aud": "swe-api", "typ": constants.TokenTypeAccess, "exp": now.Add(time.Minute).Unix()}
+
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		options  ValidationOptions
+		expected error
+	}{
+		{"valid", sign(valid), options, nil},
+		{"refresh as access", refresh, ValidationOptions{TokenType: constants.TokenTypeAccess}, ErrTokenInvalidType},
+		{"expired", sign(with("exp", now.Add(-time.Minute).Unix())), options, ErrTokenExpired},
+		{"expired within leeway", sign(with("exp", now.Add(-time.Minute).Unix())), ValidationOptions{Leeway: 2 * time.Minute}, nil},
+		{"not valid yet", sign(with("nbf", now.Add(time.Minute).Unix())), options, ErrTokenNotValidYet},
+		{"bad issuer", sign(with("iss", "other")), options, ErrTokenInvalidIssuer},
+		{"bad audience", sign(with("aud", "other")), options, ErrTokenInvalidAudience},
+		{"bad signature", sign(valid) + "x", options, ErrTokenSignatureInvalid},
+		{"malformed", "not-a-token", options, ErrTokenMalformed},
+	}
+
+	for _, test := range tests {
+		_, err := ValidateToken(test.token, HMACKeyfunc(testSecret), test.options)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("ValidateToken failed for %s: expected %v but got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+// TestPermissionsHash is a function to test PermissionsHash function.
+func TestPermissionsHash(t *testing.T) {
+	first := &sharedomain.UserPermission{Permissions: []*sharedomain.Permission{
+		{Resource: "user", Action: "READ"},
+		{Resource: "order", Action: "WRITE"},
+	}}
+	second := &sharedomain.UserPermission{Permissions: []*sharedomain.Permission{
+		{Resource: "ORDER", Action: "write"},
+		{Resource: "user", Action: "read"},
+	}}
+	third := &sharedomain.UserPermission{Permissions: []*sharedomain.Permission{
+		{Resource: "user", Action: "READ"},
+	}}
+
+	if PermissionsHash(first) != PermissionsHash(second) {
+		t.Errorf("PermissionsHash failed: expected equal hashes for %v and %v", first, second)
+	}
+	if PermissionsHash(first) == PermissionsHash(third) {
+		t.Errorf("PermissionsHash failed: expected different hashes for %v and %v", first, third)
+	}
+}
+
+// TestSignUserPermission is a function to test SignUserPermission and VerifyUserPermission functions.
+func TestSignUserPermission(t *testing.T) {
+	userPermission := &sharedomain.UserPermission{Permissions: []*sharedomain.Permission{{Resource: "user", Action: "READ"}}}
+	claims := &jwt.MapClaims{"sub": "user-1", "exp": float64(time.Now().Add(time.Hour).Unix())}
+	other := &jwt.MapClaims{"sub": "user-2", "exp": (*claims)["exp"]}
+
+	payload, signature, err := SignUserPermission(userPermission, claims, testSecret)
+	if err != nil {
+		t.Fatalf("SignUserPermission failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		payload   string
+		signature string
+		claims    *jwt.MapClaims
+		secret    string
+		expected  bool
+	}{
+		{"valid", payload, signature, claims, testSecret, true},
+		{"tampered payload", `{"permissions":[{"action":"DELETE","resource":"user"}]}`, signature, claims, testSecret, false},
+		{"other subject", payload, signature, other, testSecret, false},
+		{"other secret", payload, signature, claims, "other-secret", false},
+		{"missing signature", payload, "", claims, testSecret, false},
+	}
+
+	for _, test := range tests {
+		if actual := VerifyUserPermission(test.payload, test.signature, test.claims, test.secret); actual != test.expected {
+			t.Errorf("VerifyUserPermission failed for %s: expected %v but got %v", test.name, test.expected, actual)
+		}
+	}
+}